@@ -13,18 +13,33 @@ type AppModel struct {
 
 // 初期画面をセット
 func New() AppModel {
+	// up --daemon など別のプロセスで公開中なら、そちらの状態を表示する
+	screens.WatchRemote()
 	return AppModel{currentScreen: screens.NewWelcomeScreen()}
 }
 
 func (m AppModel) Init() tea.Cmd {
-	return m.currentScreen.Init()
+	return tea.Batch(m.currentScreen.Init(), screens.WaitForStatus())
 }
 
 func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	newModel, cmd := m.currentScreen.Update(msg)
 
+	// 公開状態の変化は表示中の画面に渡し、次の変化を待ち続ける
+	if _, ok := msg.(screens.StatusChangedMsg); ok {
+		m.currentScreen = newModel
+		return m, tea.Batch(cmd, screens.WaitForStatus())
+	}
+
 	// 画面遷移を管理
 	if msg, ok := msg.(screens.ScreenChangeMsg); ok {
+		// トークンを読み書きする画面は、先に保管庫のロックを解除する（起動してから1回だけ）
+		// プロファイルの削除でもトークンを消すので同じく解除する
+		if (msg.Screen == "generate_token" || msg.Screen == "start_frpc" || msg.Screen == "profiles") && screens.NeedsUnlock() {
+			m.currentScreen = screens.InitialUnlockVaultModel(msg.Screen)
+			return m, m.currentScreen.Init()
+		}
+
 		switch msg.Screen {
 		case "welcome":
 			m.currentScreen = screens.NewWelcomeScreen()
@@ -34,6 +49,12 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.currentScreen = screens.InitialGenerateTokenModel()
 		case "start_frpc":
 			m.currentScreen = screens.InitialStartFrpcModel()
+		case "access_list":
+			m.currentScreen = screens.InitialAccessListModel()
+		case "connections":
+			m.currentScreen = screens.InitialConnectionsModel()
+		case "profiles":
+			m.currentScreen = screens.InitialProfilesModel()
 		}
 		return m, m.currentScreen.Init() // 新しい画面の Init() を実行
 	} else {
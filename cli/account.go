@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/api"
+	"QuickPort/internal/config"
+	"QuickPort/internal/core"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// パスワードを渡す環境変数（--password-stdin を使わない場合）
+const passwordEnv = "QUICKPORT_PASSWORD"
+
+// register --email <メールアドレス> [--password-stdin]
+func runRegister(args []string, out *output) int {
+	flags := newFlagSet("register", out)
+	email := flags.String("email", "", "登録するメールアドレス")
+	passwordStdin := flags.Bool("password-stdin", false, "パスワードを標準入力から読み取る（指定しない場合は "+passwordEnv+"）")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if *email == "" {
+		return out.fail(ExitUsage, errors.New("--email を指定してください"))
+	}
+	password, err := readPassword(out.stdin, *passwordStdin)
+	if err != nil {
+		return out.fail(ExitUsage, err)
+	}
+	if err := account.ValidatePassword(password, password); err != nil {
+		return out.fail(ExitUsage, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	message, err := account.CreateAccount(ctx, *email, password)
+	if err != nil {
+		return out.fail(ExitError, err)
+	}
+	if err := account.SaveAccountEmail(*email); err != nil {
+		return out.fail(ExitError, fmt.Errorf("アカウント情報の保存に失敗しました: %w", err))
+	}
+
+	out.result(struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Email   string `json:"email"`
+	}{Status: "OK", Message: message, Email: *email}, message)
+	return ExitOK
+}
+
+// token issue --email <メールアドレス> --port <ポート> [--protocol tcp|udp] [--password-stdin]
+func runToken(args []string, out *output) int {
+	if len(args) == 0 || args[0] != "issue" {
+		fmt.Fprintln(out.stderr, "使い方: QuickPort token issue --email <メールアドレス> --port <ポート> [--protocol tcp|udp]")
+		return ExitUsage
+	}
+
+	flags := newFlagSet("token issue", out)
+	email := flags.String("email", "", "登録済みのメールアドレス")
+	port := flags.Int("port", defaultLocalPort(), "公開するローカルのポート")
+	protocol := flags.String("protocol", core.PROXY_TYPE_TCP, "プロトコル（tcp か udp）")
+	passwordStdin := flags.Bool("password-stdin", false, "パスワードを標準入力から読み取る（指定しない場合は "+passwordEnv+"）")
+	if code, ok := parseFlags(flags, args[1:]); !ok {
+		return code
+	}
+
+	if *email == "" {
+		return out.fail(ExitUsage, errors.New("--email を指定してください"))
+	}
+	protocolType := strings.ToLower(*protocol)
+	if protocolType != core.PROXY_TYPE_TCP && protocolType != core.PROXY_TYPE_UDP {
+		return out.fail(ExitUsage, errors.New("プロトコルは tcp か udp を指定してください"))
+	}
+	password, err := readPassword(out.stdin, *passwordStdin)
+	if err != nil {
+		return out.fail(ExitUsage, err)
+	}
+
+	var request api.TokenRequest
+	request.UserInfo.Email = *email
+	request.UserInfo.Password = password
+	request.Metadata.LocalIP = "127.0.0.1"
+	request.Metadata.LocalPort = *port
+	request.Metadata.ProtocolType = protocolType
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := account.IssueToken(ctx, request)
+	if err != nil {
+		return out.fail(ExitError, err)
+	}
+
+	// トークンそのものはファイルに保存したので、画面と同じく伏せて表示する
+	result.Token = account.MaskToken(result.Token)
+	text := fmt.Sprintf("トークンを発行しました: %s\nプラン: %s  帯域: %s  有効期限: %s",
+		result.Token, result.Plan, result.Bandwidth, result.ExpireAt)
+	out.result(result, text)
+	return ExitOK
+}
+
+// defaultLocalPort は設定の転送先のポートを返す（設定が無ければMinecraftの既定のポート）
+func defaultLocalPort() int {
+	if port := config.Get().Local.Port; port != 0 {
+		return port
+	}
+	return 25565
+}
+
+// readPassword は標準入力stdinの1行目か環境変数からパスワードを読み取る
+func readPassword(stdin io.Reader, fromStdin bool) (string, error) {
+	if !fromStdin {
+		password := os.Getenv(passwordEnv)
+		if password == "" {
+			return "", errors.New("パスワードを --password-stdin か環境変数 " + passwordEnv + " で指定してください")
+		}
+		return password, nil
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("パスワードの読み取りに失敗しました: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("パスワードが空です")
+	}
+	return password, nil
+}
@@ -0,0 +1,156 @@
+// Package cli はTUIを使わずに操作するためのサブコマンドを実装する
+//
+// ヘッドレスなVPSやtmuxで動かすことを想定し、結果は終了コードと、
+// そのまま読める文字列または --json を付けたときのJSONで返す
+package cli
+
+import (
+	"QuickPort/internal/config"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// 終了コード
+const (
+	ExitOK         = 0 // 成功
+	ExitError      = 1 // 失敗
+	ExitUsage      = 2 // 引数の誤り
+	ExitNotRunning = 3 // 公開していない（status / down / reload）
+)
+
+// Usage はコマンドの一覧
+const Usage = `使い方: QuickPort [--config <ファイル>] [--profile <名前>] [--log] [設定の上書き] <コマンド> [オプション]
+
+コマンド:
+  register     アカウントを登録する
+  token issue  トークンを発行して保存する
+  up           ポートを公開する（Ctrl+C か down で停止、--daemon でバックグラウンド）
+  status       公開の状態を表示する
+  down         公開しているポートを停止する
+  reload       設定とトークンを読み込み直して接続し直す
+
+コマンドを指定しない場合はTUIを起動します。
+各コマンドのオプションは QuickPort <コマンド> -h で、
+設定の上書き（--relay-addr など）は QuickPort -h で確認できます。
+トークンや設定はユーザーごとの設定ディレクトリ（QUICKPORT_STATE_DIR で変更可）に保存します。
+トークンはパスフレーズで暗号化するため、token issue と up では環境変数
+QUICKPORT_VAULT_PASSPHRASE にパスフレーズを設定してください。
+--profile を付けると、そのプロファイルのアカウント・トークン・転送先を使います
+（無ければ token issue で作成）。プロファイルごとに同時に公開できます。
+`
+
+// command はサブコマンドの実装
+type command func(args []string, out *output) int
+
+var commands = map[string]command{
+	"register": runRegister,
+	"token":    runToken,
+	"up":       runUp,
+	"status":   runStatus,
+	"down":     runDown,
+	"reload":   runReload,
+}
+
+// IsCommand はnameがサブコマンドかどうかを返す
+func IsCommand(name string) bool {
+	if name == "help" || name == "-h" || name == "--help" {
+		return true
+	}
+	_, ok := commands[name]
+	return ok
+}
+
+// Run はサブコマンドを実行して終了コードを返す
+// globalArgs はコマンドより前に指定された設定の引数で、デーモンを起動するときに引き継ぐ。
+// ログをファイルへ書き出さない場合、up 以外のコマンドはログを出さずに結果だけを出力する
+func Run(args []string, globalArgs []string) int {
+	return runCommand(args, &output{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, globalArgs: globalArgs})
+}
+
+// runCommand はサブコマンドを選んで、outの入出力で実行する
+func runCommand(args []string, out *output) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out.stdout, Usage)
+		return ExitOK
+	}
+
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(out.stderr, "不明なコマンドです: %s\n\n%s", args[0], Usage)
+		return ExitUsage
+	}
+	if !config.Get().Log.Enabled && args[0] != "up" {
+		log.SetOutput(io.Discard)
+	}
+	return run(args[1:], out)
+}
+
+// newFlagSet はサブコマンドの引数を解析する FlagSet を作る
+// すべてのコマンドで --json を受け付ける
+func newFlagSet(name string, out *output) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(out.stderr)
+	flags.BoolVar(&out.json, "json", false, "結果をJSONで出力する")
+	return flags
+}
+
+// parseFlags は引数を解析し、失敗した場合は終了コードを返す
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK, false
+		}
+		return ExitUsage, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "余分な引数があります: %v\n", flags.Args())
+		return ExitUsage, false
+	}
+	return 0, true
+}
+
+// output は結果を文字列かJSONで書き出す
+type output struct {
+	stdin      io.Reader // --password-stdin で読む
+	stdout     io.Writer
+	stderr     io.Writer
+	json       bool
+	globalArgs []string // デーモンに引き継ぐ設定の引数
+}
+
+// result は結果を出力する。JSONでない場合はtextを出力する
+func (o *output) result(v any, text string) {
+	if o.json {
+		o.writeJSON(v)
+		return
+	}
+	fmt.Fprintln(o.stdout, text)
+}
+
+// fail はエラーを出力してcodeを返す
+// JSONの場合はAPIの応答と同じ形で標準出力に書き、スクリプトから読めるようにする
+func (o *output) fail(code int, err error) int {
+	if o.json {
+		o.writeJSON(struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}{Status: "ERROR", Message: err.Error()})
+	} else {
+		fmt.Fprintln(o.stderr, "エラー:", err)
+	}
+	return code
+}
+
+func (o *output) writeJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintln(o.stderr, "エラー:", err)
+		return
+	}
+	fmt.Fprintln(o.stdout, string(data))
+}
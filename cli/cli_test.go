@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"QuickPort/internal/api"
+	"QuickPort/internal/config"
+	"QuickPort/internal/statedir"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// accounts.ini と制御用のソケットをテスト用の一時ディレクトリに置く
+	dir, err := os.MkdirTemp("", "quickport-cli")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(statedir.DirEnv, dir)
+	os.Unsetenv(passwordEnv)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// execute はstdinを標準入力としてコマンドを実行し、終了コードと出力を返す
+func execute(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(args, &output{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+// useAPI はhandlerで応答するサーバを認証APIとして使う
+func useAPI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := config.Default()
+	cfg.API.BaseURL = server.URL
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+// errorResult は --json のエラー出力
+type errorResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// コマンドが無ければ使い方を標準出力に書いて成功すること
+func TestRunHelp(t *testing.T) {
+	for _, args := range [][]string{nil, {"help"}, {"-h"}, {"--help"}} {
+		code, stdout, stderr := execute("", args...)
+		if code != ExitOK || stdout != Usage || stderr != "" {
+			t.Errorf("%v: code = %d, stdout = %q, stderr = %q", args, code, stdout, stderr)
+		}
+	}
+}
+
+// 不明なコマンドは使い方を標準エラーに書いて引数の誤りにすること
+func TestRunUnknownCommand(t *testing.T) {
+	code, stdout, stderr := execute("", "publish")
+	if code != ExitUsage {
+		t.Fatalf("code = %d, want %d", code, ExitUsage)
+	}
+	if stdout != "" || !strings.Contains(stderr, "不明なコマンドです: publish") || !strings.Contains(stderr, Usage) {
+		t.Fatalf("stdout = %q, stderr = %q", stdout, stderr)
+	}
+}
+
+// 引数が足りない・誤っている場合はAPIを呼ばずに引数の誤りにすること
+func TestRunUsageErrors(t *testing.T) {
+	useAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("API was called: %s", r.URL.Path)
+	})
+	t.Setenv(passwordEnv, "secret-password")
+
+	tests := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"register"}, "--email を指定してください"},
+		{[]string{"register", "--email"}, "flag needs an argument"},
+		{[]string{"register", "--mail", "owner@example.com"}, "flag provided but not defined"},
+		{[]string{"register", "--email", "owner@example.com", "extra"}, "余分な引数があります"},
+		{[]string{"token"}, "使い方: QuickPort token issue"},
+		{[]string{"token", "revoke"}, "使い方: QuickPort token issue"},
+		{[]string{"token", "issue", "--port", "25565"}, "--email を指定してください"},
+		{[]string{"token", "issue", "--email", "owner@example.com", "--protocol", "sctp"}, "tcp か udp"},
+		{[]string{"status", "extra"}, "余分な引数があります"},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := execute("", tt.args...)
+		if code != ExitUsage {
+			t.Errorf("%v: code = %d, want %d", tt.args, code, ExitUsage)
+		}
+		if stdout != "" || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v: stdout = %q, stderr = %q, want %q", tt.args, stdout, stderr, tt.stderr)
+		}
+	}
+}
+
+// --json では失敗もJSONで標準出力に書くこと
+func TestRunJSONError(t *testing.T) {
+	code, stdout, stderr := execute("", "register", "--json")
+	if code != ExitUsage {
+		t.Fatalf("code = %d, want %d", code, ExitUsage)
+	}
+	if stderr != "" {
+		t.Fatalf("stderr = %q, want nothing", stderr)
+	}
+	var result errorResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("stdout is not JSON: %q", stdout)
+	}
+	if result.Status != "ERROR" || result.Message != "--email を指定してください" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+// パスワードが無い・空・短い場合は登録しないこと
+func TestRegisterPasswordErrors(t *testing.T) {
+	useAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("API was called: %s", r.URL.Path)
+	})
+
+	tests := []struct {
+		stdin  string
+		args   []string
+		stderr string
+	}{
+		{"", nil, passwordEnv},
+		{"", []string{"--password-stdin"}, "パスワードが空です"},
+		{"\r\n", []string{"--password-stdin"}, "パスワードが空です"},
+		{"abc\n", []string{"--password-stdin"}, "エラー:"},
+	}
+	for _, tt := range tests {
+		args := append([]string{"register", "--email", "owner@example.com"}, tt.args...)
+		code, _, stderr := execute(tt.stdin, args...)
+		if code != ExitUsage || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("stdin %q: code = %d, stderr = %q, want %d and %q", tt.stdin, code, stderr, ExitUsage, tt.stderr)
+		}
+	}
+}
+
+// --password-stdin の1行目をパスワードとして登録し、結果をJSONで返すこと
+func TestRegisterPasswordStdin(t *testing.T) {
+	var request api.SignupRequest
+	useAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/signup" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(api.Response{Status: api.STATUS_OK, Message: "確認メールを送信しました"})
+	})
+
+	code, stdout, stderr := execute("secret-password\r\nignored\n", "register", "--email", "owner@example.com", "--password-stdin", "--json")
+	if code != ExitOK {
+		t.Fatalf("code = %d, stdout = %q, stderr = %q", code, stdout, stderr)
+	}
+	if request.Email != "owner@example.com" || request.Password != "secret-password" {
+		t.Fatalf("request = %+v", request)
+	}
+	var result struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("stdout is not JSON: %q", stdout)
+	}
+	if result.Status != "OK" || result.Message != "確認メールを送信しました" || result.Email != "owner@example.com" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+// APIが失敗した場合はそのメッセージで失敗にすること
+func TestRegisterAPIError(t *testing.T) {
+	useAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(api.Response{Status: api.STATUS_ERROR, Message: "登録済みのメールアドレスです"})
+	})
+	t.Setenv(passwordEnv, "secret-password")
+
+	code, stdout, _ := execute("", "register", "--email", "owner@example.com", "--json")
+	if code != ExitError {
+		t.Fatalf("code = %d, want %d", code, ExitError)
+	}
+	var result errorResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("stdout is not JSON: %q", stdout)
+	}
+	if result.Status != "ERROR" || result.Message != "登録済みのメールアドレスです" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+// 公開していない場合、status・down・reload は専用の終了コードを返すこと
+func TestRunNotRunning(t *testing.T) {
+	for _, name := range []string{"status", "down", "reload"} {
+		code, _, _ := execute("", name)
+		if code != ExitNotRunning {
+			t.Errorf("%s: code = %d, want %d", name, code, ExitNotRunning)
+		}
+	}
+
+	code, stdout, _ := execute("", "status", "--json")
+	if code != ExitNotRunning {
+		t.Fatalf("status --json: code = %d, want %d", code, ExitNotRunning)
+	}
+	var result struct {
+		Running bool   `json:"running"`
+		Profile string `json:"profile"`
+		State   string `json:"state"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("stdout is not JSON: %q", stdout)
+	}
+	if result.Running || result.Profile == "" || result.State == "" {
+		t.Fatalf("result = %+v", result)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/config"
+	"QuickPort/internal/control"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// デーモンが接続を終えるまで待つ時間
+const daemonStartTimeout = 30 * time.Second
+
+// startDaemon は自分自身を up としてバックグラウンドで起動し、リレーに接続するまで待つ
+// 設定の引数はそのまま引き継ぎ、デーモンのログは設定のログファイル（既定は qp.log）に書き出す
+func startDaemon(out *output) int {
+	if control.Running(account.SocketPath()) {
+		return out.fail(ExitError, errors.New("すでに別のプロセスで公開中です"))
+	}
+	// パスフレーズの誤りなどはデーモンのログではなくここで伝える
+	if _, err := readToken(); err != nil {
+		return out.fail(ExitError, err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return out.fail(ExitError, err)
+	}
+	args := append(append([]string{}, out.globalArgs...), "--log", "up")
+	cmd := exec.Command(executable, args...)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return out.fail(ExitError, fmt.Errorf("デーモンの起動に失敗しました: %w", err))
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(daemonStartTimeout)
+	for {
+		select {
+		case <-exited:
+			return out.fail(ExitError, fmt.Errorf("デーモンが終了しました。%s を確認してください", config.Get().Log.File))
+		case <-deadline:
+			return out.fail(ExitError, fmt.Errorf("%v 待っても接続できませんでした (PID %d はバックグラウンドで動作中)", daemonStartTimeout, cmd.Process.Pid))
+		case <-ticker.C:
+			snapshot, err := control.Status(account.SocketPath())
+			if err != nil || !snapshot.Status.Connected() {
+				continue
+			}
+			state := newRunState(snapshot)
+			out.result(state, fmt.Sprintf("バックグラウンドで公開しました (PID %d): %s", state.PID, state.PublicAddr))
+			return ExitOK
+		}
+	}
+}
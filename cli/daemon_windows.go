@@ -0,0 +1,18 @@
+//go:build windows
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// コンソールを持たずにプロセスを起動する（DETACHED_PROCESS）
+const detachedProcess = 0x00000008
+
+// detach はデーモンをコンソールから切り離して起動し、ウィンドウを閉じても止まらないようにする
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
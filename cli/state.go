@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/control"
+	"strconv"
+	"time"
+)
+
+// runState は status が出力する公開中のトンネルの状態
+type runState struct {
+	Running     bool         `json:"running"`
+	Profile     string       `json:"profile"`
+	PID         int          `json:"pid"`
+	Started     time.Time    `json:"started"`
+	State       string       `json:"state"`
+	Connected   bool         `json:"connected"`
+	PublicAddr  string       `json:"public_addr,omitempty"`
+	Proxies     []proxyState `json:"proxies,omitempty"`
+	Connections int          `json:"connections"`
+	BytesIn     uint64       `json:"bytes_in"`
+	BytesOut    uint64       `json:"bytes_out"`
+	Rejected    uint64       `json:"rejected"`
+	RTTMillis   int64        `json:"rtt_ms"`
+	LastError   string       `json:"last_error,omitempty"`
+}
+
+type proxyState struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Local      string `json:"local"`
+	RemotePort int    `json:"remote_port"`
+}
+
+func newRunState(snapshot control.Snapshot) runState {
+	status := snapshot.Status
+	state := runState{
+		Running:     status.Running,
+		Profile:     account.CurrentProfile(),
+		PID:         snapshot.PID,
+		Started:     snapshot.Started,
+		State:       status.State.String(),
+		Connected:   status.Connected(),
+		PublicAddr:  status.PublicAddr,
+		Connections: len(snapshot.Connections),
+		BytesIn:     snapshot.Traffic.BytesIn,
+		BytesOut:    snapshot.Traffic.BytesOut,
+		Rejected:    snapshot.Rejections.Total(),
+		RTTMillis:   snapshot.RTT.Milliseconds(),
+		LastError:   firstNonEmpty(status.LastError, status.State.LastError),
+	}
+	for _, proxy := range status.Proxies {
+		host := proxy.LocalIP
+		if host == "" {
+			host = "localhost"
+		}
+		state.Proxies = append(state.Proxies, proxyState{
+			Name:       proxy.Name,
+			Type:       proxy.Type,
+			Local:      host + ":" + strconv.Itoa(proxy.LocalPort),
+			RemotePort: proxy.RemotePort,
+		})
+	}
+	return state
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/control"
+	"QuickPort/internal/core"
+	"QuickPort/internal/vault"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// tunnel は up で公開中のFRPクライアントを保持し、制御APIからの操作を受け付ける
+type tunnel struct {
+	started time.Time
+	stop    context.CancelFunc // up 全体を止める
+
+	mutex     sync.Mutex
+	client    *core.FRPClient
+	cancel    context.CancelFunc // 今のクライアントだけを止める
+	reloading bool
+}
+
+func (t *tunnel) Snapshot(full bool) control.Snapshot {
+	t.mutex.Lock()
+	client := t.client
+	t.mutex.Unlock()
+
+	if client == nil {
+		return control.Snapshot{PID: os.Getpid(), Started: t.started}
+	}
+	return control.NewSnapshot(client, os.Getpid(), t.started, full)
+}
+
+func (t *tunnel) Stop() {
+	t.stop()
+}
+
+// Reload は今のクライアントを止め、トークンと設定を読み込み直して接続し直す
+// 接続中のプレイヤーは切断される
+func (t *tunnel) Reload() error {
+	// 読めないトークンで公開を止めてしまわないように、先に確かめる
+	if _, err := account.ReadToken(); err != nil {
+		return fmt.Errorf("トークンの読み取りに失敗しました: %w", err)
+	}
+
+	t.mutex.Lock()
+	t.reloading = true
+	cancel := t.cancel
+	t.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+func (t *tunnel) Kick(connID string) error {
+	t.mutex.Lock()
+	client := t.client
+	t.mutex.Unlock()
+
+	if client == nil {
+		return control.ErrNotRunning
+	}
+	return client.Kick(connID)
+}
+
+// run はトークンと設定を読み込んでクライアントを起動し、終了するまで状態の変化を出力する
+// Reload で止めた場合は reloaded が true になる
+func (t *tunnel) run(ctx context.Context, out *output) (reloaded bool, err error) {
+	token, err := readToken()
+	if err != nil {
+		return false, err
+	}
+
+	client := account.NewFRPClient(token)
+	clientCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t.mutex.Lock()
+	t.client, t.cancel, t.reloading = client, cancel, false
+	t.mutex.Unlock()
+
+	updates, unsubscribe := client.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Start(clientCtx)
+	}()
+
+	var last core.Status
+	for {
+		select {
+		case status := <-updates:
+			if !status.Running && !last.Running {
+				// Start() を呼ぶ前の状態は出力しない
+				continue
+			}
+			if status.Running && status.State.Kind == core.StateIdle {
+				// 接続を始める前と、終了する直前の一時的な状態
+				continue
+			}
+			if status.Running == last.Running && status.State == last.State {
+				// 公開アドレスなどが決まっただけで、接続状態は変わっていない
+				last = status
+				continue
+			}
+			last = status
+			printStatus(out, status)
+		case err := <-done:
+			// 終了時の状態は Start() が戻る前に届いている
+			select {
+			case status := <-updates:
+				printStatus(out, status)
+			default:
+			}
+			t.mutex.Lock()
+			reloaded = t.reloading
+			t.mutex.Unlock()
+			return reloaded, err
+		}
+	}
+}
+
+// readToken は保管庫からトークンを読み取る
+func readToken() (string, error) {
+	token, err := account.ReadToken()
+	if errors.Is(err, vault.ErrLocked) || errors.Is(err, vault.ErrWrongPassphrase) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("トークンの読み取りに失敗しました。先に token issue を実行してください: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/control"
+	"QuickPort/internal/core"
+	"QuickPort/internal/traffic"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// up [--daemon] [--json]
+func runUp(args []string, out *output) int {
+	flags := newFlagSet("up", out)
+	daemon := flags.Bool("daemon", false, "バックグラウンドで公開を続ける（停止は down）")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	if *daemon {
+		return startDaemon(out)
+	}
+
+	// 別のターミナルのCLIやTUIから操作できるように、制御APIを待ち受ける
+	listener, err := control.Listen(account.SocketPath())
+	if err != nil {
+		return out.fail(ExitError, err)
+	}
+	defer listener.Close()
+
+	// Ctrl+C と SIGTERM で停止する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	t := &tunnel{started: time.Now(), stop: stop}
+	go control.Serve(listener, t)
+	// 公開を止めたら、起動したサーバも止める（読み込み直しでは止めない）
+	defer account.StopLocalServer()
+
+	for {
+		reloaded, err := t.run(ctx, out)
+		if reloaded && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return out.fail(ExitError, err)
+		}
+		return ExitOK
+	}
+}
+
+// printStatus は状態の変化を1行で出力する
+func printStatus(out *output, status core.Status) {
+	if out.json {
+		out.writeJSON(statusEvent{
+			Time:       time.Now(),
+			Running:    status.Running,
+			State:      status.State.Kind.String(),
+			PublicAddr: status.PublicAddr,
+			LastError:  firstNonEmpty(status.LastError, status.State.LastError),
+		})
+		return
+	}
+
+	line := fmt.Sprintf("[%s] %s", time.Now().Format(time.TimeOnly), status.State)
+	if status.Connected() && status.PublicAddr != "" {
+		line += "  " + status.PublicAddr
+	}
+	if lastError := firstNonEmpty(status.LastError, status.State.LastError); lastError != "" {
+		line += "  " + lastError
+	}
+	fmt.Fprintln(out.stdout, line)
+}
+
+// statusEvent は up --json が状態の変化ごとに出力する1行
+type statusEvent struct {
+	Time       time.Time `json:"time"`
+	Running    bool      `json:"running"`
+	State      string    `json:"state"`
+	PublicAddr string    `json:"public_addr,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// status [--json]
+func runStatus(args []string, out *output) int {
+	flags := newFlagSet("status", out)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	snapshot, err := control.Stats(account.SocketPath())
+	if errors.Is(err, control.ErrNotRunning) {
+		out.result(struct {
+			Running bool   `json:"running"`
+			Profile string `json:"profile"`
+			State   string `json:"state"`
+		}{Running: false, Profile: account.CurrentProfile(), State: core.StateIdle.String()}, "公開していません")
+		return ExitNotRunning
+	}
+	if err != nil {
+		return out.fail(ExitError, err)
+	}
+
+	state := newRunState(snapshot)
+	text := fmt.Sprintf("プロファイル: %s\n状態:       %s\n公開アドレス: %s\nPID:        %d\n起動:       %s\n接続数:     %d\n通信量:     ↓%s ↑%s\n拒否:       %d",
+		state.Profile, state.State, firstNonEmpty(state.PublicAddr, "-"), state.PID, state.Started.Format(time.DateTime),
+		state.Connections, traffic.FormatBytes(state.BytesIn), traffic.FormatBytes(state.BytesOut), state.Rejected)
+	for _, proxy := range state.Proxies {
+		text += fmt.Sprintf("\nプロキシ:   %s/%s %s -> :%d", proxy.Name, proxy.Type, proxy.Local, proxy.RemotePort)
+	}
+	if state.LastError != "" {
+		text += "\n直前のエラー: " + state.LastError
+	}
+	out.result(state, text)
+	return ExitOK
+}
+
+// 停止を待つ時間
+const downTimeout = 15 * time.Second
+
+// down [--json]
+func runDown(args []string, out *output) int {
+	flags := newFlagSet("down", out)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	snapshot, err := control.Status(account.SocketPath())
+	if errors.Is(err, control.ErrNotRunning) {
+		return out.fail(ExitNotRunning, err)
+	}
+	if err == nil {
+		err = control.Stop(account.SocketPath())
+	}
+	if err != nil {
+		return out.fail(ExitError, fmt.Errorf("停止に失敗しました: %w", err))
+	}
+
+	// ストリームの切断通知を送り終えて終了するまで待つ
+	deadline := time.Now().Add(downTimeout)
+	for control.Running(account.SocketPath()) {
+		if time.Now().After(deadline) {
+			return out.fail(ExitError, fmt.Errorf("%v 待っても停止しませんでした (PID %d)", downTimeout, snapshot.PID))
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	out.result(struct {
+		Status string `json:"status"`
+		PID    int    `json:"pid"`
+	}{Status: "OK", PID: snapshot.PID}, "停止しました")
+	return ExitOK
+}
+
+// reload [--json]
+func runReload(args []string, out *output) int {
+	flags := newFlagSet("reload", out)
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	err := control.Reload(account.SocketPath())
+	if errors.Is(err, control.ErrNotRunning) {
+		return out.fail(ExitNotRunning, err)
+	}
+	if err != nil {
+		return out.fail(ExitError, fmt.Errorf("再読み込みに失敗しました: %w", err))
+	}
+
+	out.result(struct {
+		Status string `json:"status"`
+	}{Status: "OK"}, "設定とトークンを読み込み直して接続し直します")
+	return ExitOK
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -1,23 +1,82 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"QuickPort/app"
+	"QuickPort/cli"
+	"QuickPort/internal/account"
+	"QuickPort/internal/acl"
+	"QuickPort/internal/config"
+	"QuickPort/internal/statedir"
+	"QuickPort/internal/traffic"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	// os.Exit は defer を実行しないので、ログファイルを閉じてから終了コードを返す
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	// コマンドより前の引数で、設定ファイルの場所と設定の上書きを受け付ける
+	flags := flag.NewFlagSet("QuickPort", flag.ContinueOnError)
+	configPath := flags.String("config", "", "設定ファイル（既定は "+config.DefaultPath()+" か環境変数 "+config.PathEnv+"）")
+	profile := flags.String("profile", "", "使用するプロファイル（既定はTUIで最後に選んだプロファイル）")
+	logging := flags.Bool("log", false, "ログをファイルへ書き出す（--log-enabled と同じ）")
+	overrides := make(map[string]string)
+	config.RegisterFlags(flags, overrides)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), cli.Usage+"\nオプション:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cli.ExitOK
+		}
+		return cli.ExitUsage
+	}
+	globalArgs := args[:len(args)-flags.NArg()]
+	args = flags.Args()
+	// 以前のバージョンがカレントディレクトリなどに保存したファイルを、初回だけ状態ディレクトリへ移す
+	// 以前のバージョンが実行ファイルのフォルダに保存したファイルを、初回だけ状態ディレクトリへ移す
+	migrateFiles := []string{statedir.TokenFile, statedir.AccountsFile, statedir.LogFile, acl.FileName, traffic.FileName}
+	if *configPath == "" && os.Getenv(config.PathEnv) == "" {
+		migrateFiles = append(migrateFiles, config.FileName)
+	}
+	moved, err := statedir.Prepare(migrateFiles...)
+	for _, name := range moved {
+		fmt.Fprintf(os.Stderr, "%s を %s へ移動しました\n", name, statedir.Dir())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "保存先の準備に失敗しました:", err)
+	}
+
 	// プログラム引数でlog出力を有効にする
-	if len(os.Args) > 1 && os.Args[1] == "--log" {
+	if *logging {
+		overrides["log.enabled"] = "true"
+	}
+	cfg, err := config.Load(*configPath, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "設定の読み込みに失敗しました:", err)
+		return cli.ExitUsage
+	}
+	config.Set(cfg)
+	for _, notice := range account.LegacySettings() {
+		fmt.Fprintln(os.Stderr, notice)
+	}
+
+	if cfg.Log.Enabled {
 		// ログファイルを作成または開く
-		logFile, err := os.OpenFile("qp.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+		logFile, err := os.OpenFile(cfg.Log.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 		if err != nil {
 			fmt.Println("Failed to open log file:", err)
-			return
+			return 1
 		}
 		defer logFile.Close()
 
@@ -28,9 +87,25 @@ func main() {
 		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	}
 
+	if *profile != "" {
+		if err := account.UseProfile(*profile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return cli.ExitUsage
+		}
+	}
+
+	// サブコマンドが指定された場合はTUIを使わずに実行する
+	if len(args) > 0 && cli.IsCommand(args[0]) {
+		return cli.Run(args, globalArgs)
+	}
+
 	p := tea.NewProgram(app.New(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	// 終了するときは起動を任せたサーバも止める
+	account.StopLocalServer()
+	if err != nil {
 		fmt.Printf("エラーが発生しました: %v", err)
-		os.Exit(1)
+		return 1
 	}
+	return 0
 }
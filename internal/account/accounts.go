@@ -0,0 +1,128 @@
+package account
+
+import (
+	"QuickPort/internal/config"
+	"QuickPort/internal/statedir"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// loadAccounts は状態ディレクトリの accounts.ini を読み込む
+func loadAccounts() (*ini.File, error) {
+	return ini.Load(statedir.Path(statedir.AccountsFile))
+}
+
+// saveAccounts は accounts.ini を本人だけが読めるように書き出す
+func saveAccounts(cfg *ini.File) error {
+	var buf bytes.Buffer
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return err
+	}
+	return statedir.WriteFile(statedir.Path(statedir.AccountsFile), buf.Bytes(), statedir.SecretPerm)
+}
+
+// SaveAccountEmail はアカウントのメールアドレスを accounts.ini の使用中のプロファイルに書き出す
+func SaveAccountEmail(email string) error {
+	// アカウント情報は作り直すが、他のプロファイルのセクションは残す
+	cfg, err := loadAccounts()
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	name := profileSection(CurrentProfile())
+	cfg.DeleteSection(name)
+
+	// セクションとキーを設定
+	section, err := cfg.NewSection(name)
+	if err != nil {
+		return err
+	}
+	_, err = section.NewKey("Email", email)
+	if err != nil {
+		return err
+	}
+
+	// ファイルに保存（上書き）
+	err = saveAccounts(cfg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// updateAccountInfo は accounts.ini の使用中のプロファイルにアカウント情報と転送先を更新する
+func updateAccountInfo(email, plan, bandwidth, expireAt, localIP string, localPort int) error {
+	// accounts.ini ファイルを読み込み、存在しない場合は新しく作成
+	cfg, err := loadAccounts()
+	if err != nil {
+		// ファイルが存在しない場合は新しく作成
+		cfg = ini.Empty()
+	}
+
+	// プロファイルのセクションを取得または作成
+	section := cfg.Section(profileSection(CurrentProfile()))
+
+	// メールアドレス、プラン、帯域幅を設定（空でない場合のみ）
+	if email != "" {
+		section.Key("Email").SetValue(email)
+	}
+	if plan != "" {
+		section.Key("Plan").SetValue(plan)
+	}
+	if bandwidth != "" {
+		section.Key("Bandwidth").SetValue(bandwidth)
+	}
+	if expireAt != "" {
+		section.Key("ExpireAt").SetValue(expireAt)
+	}
+	if localIP != "" {
+		section.Key("LocalIP").SetValue(localIP)
+	}
+	if localPort != 0 {
+		section.Key("LocalPort").SetValue(strconv.Itoa(localPort))
+	}
+
+	// ファイルに保存
+	return saveAccounts(cfg)
+}
+
+// 以前のバージョンで accounts.ini に書いていた動作設定と、quickport.yaml での置き場所
+var legacySections = []struct {
+	section string
+	key     string
+}{
+	{"Relay", "relay"},
+	{"RateLimit", "rate_limit"},
+	{"Bandwidth", "bandwidth"},
+	{"Offline", "offline"},
+	{"WakeOnJoin", "wake_on_join"},
+	{"Proxy.*", "proxies"},
+}
+
+// LegacySettings は accounts.ini に残っている、今は読み込まない動作設定の案内を返す
+// 資格情報のファイルからコマンドなどを読み込まないように、quickport.yaml へ移してもらう
+func LegacySettings() []string {
+	cfg, err := loadAccounts()
+	if err != nil {
+		return nil
+	}
+
+	var notices []string
+	for _, legacy := range legacySections {
+		found := cfg.HasSection(legacy.section)
+		if prefix, ok := strings.CutSuffix(legacy.section, "*"); ok {
+			for _, section := range cfg.Sections() {
+				found = found || strings.HasPrefix(section.Name(), prefix)
+			}
+		}
+		if found {
+			notices = append(notices, fmt.Sprintf("accounts.ini の [%s] は使われなくなりました。%s の %s に移してください",
+				legacy.section, config.FileName, legacy.key))
+		}
+	}
+	return notices
+}
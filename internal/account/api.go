@@ -0,0 +1,85 @@
+package account
+
+import (
+	"QuickPort/internal/api"
+	"QuickPort/internal/config"
+	"QuickPort/internal/vault"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// newAPIClient は設定の認証APIに接続するクライアントを作る
+func newAPIClient() *api.Client {
+	cfg := config.Get()
+	return api.New(cfg.API.BaseURL, api.Options{Timeout: cfg.Timeouts.API})
+}
+
+// Ping は認証サーバがオンラインか確認する
+func Ping(ctx context.Context) error {
+	return newAPIClient().Ping(ctx)
+}
+
+// パスワードのバリデーション関数
+func ValidatePassword(password, confirmPassword string) error {
+	if len(password) < 5 {
+		return errors.New("パスワードは5文字以上である必要があります")
+	}
+
+	// 確認用パスワードと一致しているか
+	if password != confirmPassword {
+		return errors.New("パスワードが一致しません")
+	}
+
+	return nil
+}
+
+// CreateAccount はアカウントを登録し、サーバからのメッセージを返す
+// 画面とCLIのどちらからも同じ処理で登録する
+func CreateAccount(ctx context.Context, email, password string) (string, error) {
+	response, err := newAPIClient().Signup(ctx, api.SignupRequest{
+		Email:    email,
+		Password: password,
+	})
+	if err != nil {
+		log.Printf("アカウントの登録に失敗しました: %v", err)
+		return "", err
+	}
+
+	// パース結果をログに出力
+	log.Printf("レスポンス: message=%s, status=%s", response.Message, response.Status)
+	return response.Message, nil
+}
+
+// IssueToken はトークンを発行し、保管庫と accounts.ini に保存する
+// 画面とCLIのどちらからも同じ処理で発行する
+func IssueToken(ctx context.Context, request api.TokenRequest) (api.TokenResponse, error) {
+	// 発行したトークンを保存できない状態では発行しない
+	if _, err := vault.Session(); err != nil {
+		return api.TokenResponse{}, err
+	}
+
+	parsedResponse, err := newAPIClient().IssueToken(ctx, request)
+	if err != nil {
+		log.Printf("トークンの発行に失敗しました: %v", err)
+		return parsedResponse, err
+	}
+
+	// パース結果をログに出力
+	log.Printf("レスポンス: message=%s, status=%s, token=%s", parsedResponse.Message, parsedResponse.Status, MaskToken(parsedResponse.Token))
+
+	// トークンをファイルに書き出す
+	if err := WriteToken(parsedResponse.Token); err != nil {
+		log.Printf("トークンのファイル書き出しに失敗しました: %v", err)
+		return parsedResponse, fmt.Errorf("トークンのファイル書き出しに失敗しました: %w", err)
+	}
+
+	// アカウント情報をaccounts.iniに保存
+	if err := updateAccountInfo(parsedResponse.Email, parsedResponse.Plan, parsedResponse.Bandwidth, parsedResponse.ExpireAt,
+		request.Metadata.LocalIP, request.Metadata.LocalPort); err != nil {
+		log.Printf("アカウント情報の更新に失敗しました: %v", err)
+		// アカウント情報の更新に失敗してもトークンは有効なので、エラーにはしない
+	}
+	return parsedResponse, nil
+}
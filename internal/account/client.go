@@ -0,0 +1,194 @@
+package account
+
+import (
+	"QuickPort/internal/acl"
+	"QuickPort/internal/config"
+	"QuickPort/internal/core"
+	"QuickPort/internal/minecraft"
+	"QuickPort/internal/supervisor"
+	"QuickPort/internal/traffic"
+	"log"
+	"sync"
+)
+
+// wake_on_join で起動を任せるサーバ
+// 読み込み直しでクライアントを作り直しても起動したサーバを止めないように、プロセスで1つだけ作る
+var (
+	localServerMutex sync.Mutex
+	localServer      *supervisor.Supervisor
+)
+
+// NewFRPClient は設定ファイルとプロファイルの設定からFRPクライアントを作る
+// wake_on_join の設定がある場合は LocalServer に起動を任せるので、公開を止めたら StopLocalServer を呼ぶ
+func NewFRPClient(token string) *core.FRPClient {
+	cfg := config.Get()
+	options := core.Options{
+		TLS: relayTLSConfig(cfg),
+		Heartbeat: core.HeartbeatConfig{
+			Interval: cfg.Timeouts.HeartbeatInterval,
+			Timeout:  cfg.Timeouts.HeartbeatTimeout,
+		},
+		DialTimeout:      cfg.Timeouts.Dial,
+		PublicHost:       cfg.Relay.PublicHost,
+		LocalIP:          cfg.Local.IP,
+		LocalPort:        cfg.Local.Port,
+		UDPIdleTimeout:   cfg.Timeouts.UDPIdle,
+		Proxies:          localProxies(cfg),
+		InspectMinecraft: true,
+		Offline:          offlineConfig(cfg),
+		RateLimit:        rateLimitConfig(cfg),
+		ShapeBandwidth:   cfg.Bandwidth.Shape,
+		BandwidthLimit:   cfg.Bandwidth.Limit,
+	}
+	// 設定で転送先を指定していなければ、プロファイルの転送先を使う
+	if profile, err := LoadProfile(CurrentProfile()); err == nil {
+		if options.LocalIP == "" {
+			options.LocalIP = profile.LocalIP
+		}
+		if options.LocalPort == 0 {
+			options.LocalPort = profile.LocalPort
+		}
+	}
+	trafficLog, err := traffic.Open(traffic.DefaultPath())
+	if err != nil {
+		log.Printf("Failed to load %s: %v", traffic.DefaultPath(), err)
+	}
+	options.TrafficLog = trafficLog
+	accessList, err := acl.NewList(acl.DefaultPath())
+	if err != nil {
+		log.Printf("Failed to load %s: %v", acl.DefaultPath(), err)
+	}
+	options.AccessList = accessList
+	if server := LocalServer(); server != nil {
+		options.Waker = server
+	}
+	return core.NewFRPClientWithOptions(cfg.Relay.Addr, token, options)
+}
+
+// LocalServer は wake_on_join で起動を任せるサーバを返す（起動コマンドが無ければnil）
+// 最初に呼んだときの設定で作り、読み込み直しても同じサーバを使い続ける
+func LocalServer() *supervisor.Supervisor {
+	localServerMutex.Lock()
+	defer localServerMutex.Unlock()
+	if localServer == nil {
+		localServer = newSupervisor(config.Get())
+	}
+	return localServer
+}
+
+// StopLocalServer は起動を任せたサーバを止める
+// 読み込み直しでは呼ばず、公開を止めたときとプロセスを終了するときに呼ぶ
+func StopLocalServer() {
+	localServerMutex.Lock()
+	server := localServer
+	localServerMutex.Unlock()
+
+	if server != nil {
+		if err := server.Stop(); err != nil {
+			log.Printf("Failed to stop local server: %v", err)
+		}
+	}
+}
+
+// relayTLSConfig は設定の relay から制御コネクションのTLS設定を作る
+func relayTLSConfig(cfg config.Config) core.TLSConfig {
+	return core.TLSConfig{
+		Enabled:    cfg.Relay.TLS,
+		ServerName: cfg.Relay.ServerName,
+		PinnedSPKI: cfg.Relay.PinnedSPKI,
+	}
+}
+
+// offlineConfig は設定の offline から、ローカルサーバが停止中のときの応答を作る
+// 無効な場合は応答せず、従来どおり接続を切る
+func offlineConfig(cfg config.Config) *minecraft.OfflineConfig {
+	offline := cfg.Offline
+	if !offline.Enabled {
+		return nil
+	}
+
+	result := minecraft.DefaultOfflineConfig()
+	setIfNotEmpty(&result.MOTD, offline.MOTD)
+	setIfNotEmpty(&result.VersionName, offline.VersionName)
+	setIfNotEmpty(&result.DisconnectMessage, offline.DisconnectMessage)
+	setIfNotEmpty(&result.StartingMOTD, offline.StartingMOTD)
+	setIfNotEmpty(&result.StartingMessage, offline.StartingMessage)
+	if offline.MaxPlayers > 0 {
+		result.MaxPlayers = offline.MaxPlayers
+	}
+	result.OnlinePlayers = offline.OnlinePlayers
+	if offline.Favicon != "" {
+		favicon, err := minecraft.LoadFavicon(offline.Favicon)
+		if err != nil {
+			log.Printf("Failed to load favicon %s: %v", offline.Favicon, err)
+		} else {
+			result.Favicon = favicon
+		}
+	}
+	return &result
+}
+
+func setIfNotEmpty(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// rateLimitConfig は設定の rate_limit から新しい接続の受け入れ制限を作る
+func rateLimitConfig(cfg config.Config) core.RateLimitConfig {
+	return core.RateLimitConfig{
+		Rate:            cfg.RateLimit.Rate,
+		Burst:           cfg.RateLimit.Burst,
+		PerIPRate:       cfg.RateLimit.PerIPRate,
+		PerIPBurst:      cfg.RateLimit.PerIPBurst,
+		MaxStreams:      cfg.RateLimit.MaxStreams,
+		MaxStreamsPerIP: cfg.RateLimit.MaxStreamsPerIP,
+		AttackThreshold: cfg.RateLimit.AttackThreshold,
+	}
+}
+
+// newSupervisor は設定の wake_on_join から、プレイヤーが来たときに起動するサーバを作る
+// 起動コマンドが無ければnilを返す
+func newSupervisor(cfg config.Config) *supervisor.Supervisor {
+	wake := cfg.WakeOnJoin
+	if wake.Command == "" {
+		return nil
+	}
+
+	return supervisor.New(supervisor.Config{
+		Command:      wake.Command,
+		Dir:          wake.Dir,
+		Addr:         wake.Addr,
+		IdleTimeout:  wake.IdleTimeout,
+		StartTimeout: wake.StartTimeout,
+		StopCommand:  wake.StopCommand,
+	})
+}
+
+// localProxies は設定の proxies から追加で公開するプロキシを作る
+func localProxies(cfg config.Config) []core.ProxyConfig {
+	var proxies []core.ProxyConfig
+	for _, proxy := range cfg.Proxies {
+		if proxy.Name == "" {
+			continue
+		}
+		proxyProtocol, err := core.ParseProxyProtocol(proxy.ProxyProtocol)
+		if err != nil {
+			log.Printf("Proxy %s: %v", proxy.Name, err)
+		}
+		proxyType := proxy.Type
+		if proxyType == "" {
+			proxyType = core.PROXY_TYPE_TCP
+		}
+		proxies = append(proxies, core.ProxyConfig{
+			Name:          proxy.Name,
+			Type:          proxyType,
+			LocalIP:       proxy.LocalIP,
+			LocalPort:     proxy.LocalPort,
+			RemotePort:    proxy.RemotePort,
+			ProxyProtocol: proxyProtocol,
+			Minecraft:     proxy.Minecraft,
+		})
+	}
+	return proxies
+}
@@ -0,0 +1,202 @@
+// Package account は画面とCLIで共有するプロファイル・トークン・accounts.ini の読み書きをまとめる
+package account
+
+import (
+	"QuickPort/internal/control"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+// プロファイルはサーバごとのアカウント・トークン・転送先の組
+// accounts.ini では既定のプロファイルを [Account]、それ以外を [Account.<名前>] に保存し、
+// トークンは保管庫に token（既定）と token.<名前> の名前で保存する
+//
+//	Profile = survival
+//
+//	[Account.survival]
+//	Email     = owner@example.com
+//	Plan      = Free
+//	Bandwidth = 10Mbps
+//	ExpireAt  = 2027-07-20T21:04:44+09:00
+//	LocalIP   = 127.0.0.1
+//	LocalPort = 25565
+//
+// 先頭の Profile は最後に選んだプロファイル（--profile を付けた場合はそちらを使う）
+
+// 既定のプロファイルの名前
+const DefaultProfile = "default"
+
+// accounts.ini でプロファイルの設定を置くセクション
+const accountSection = "Account"
+
+// プロファイル名に使える文字
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Profile は1つのプロファイルに保存した情報
+type Profile struct {
+	Name      string
+	Email     string
+	Plan      string
+	Bandwidth string
+	ExpireAt  string
+	LocalIP   string // 空ならトークンの転送先
+	LocalPort int    // 0ならトークンの転送先
+}
+
+// --profile で指定した、または画面で選んだこのプロセスのプロファイル
+var (
+	profileMutex   sync.Mutex
+	sessionProfile string
+)
+
+// ValidateProfileName はプロファイル名に使えない文字が無いか確かめる
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("プロファイル名は英数字・-・_ の32文字以内にしてください: %q", name)
+	}
+	return nil
+}
+
+// UseProfile はこのプロセスで使うプロファイルを決める（accounts.ini の選択は変えない）
+func UseProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	sessionProfile = name
+	return nil
+}
+
+// CurrentProfile は使用中のプロファイル名を返す
+func CurrentProfile() string {
+	profileMutex.Lock()
+	name := sessionProfile
+	profileMutex.Unlock()
+	if name != "" {
+		return name
+	}
+
+	cfg, err := loadAccounts()
+	if err != nil {
+		return DefaultProfile
+	}
+	name = cfg.Section(ini.DefaultSection).Key("Profile").String()
+	if ValidateProfileName(name) != nil {
+		return DefaultProfile
+	}
+	return name
+}
+
+// SelectProfile は使用中のプロファイルを切り替え、次に起動したときも使うように accounts.ini に書き出す
+func SelectProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	cfg, err := loadAccounts()
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	cfg.Section(ini.DefaultSection).Key("Profile").SetValue(name)
+	cfg.Section(profileSection(name))
+	if err := saveAccounts(cfg); err != nil {
+		return err
+	}
+
+	profileMutex.Lock()
+	sessionProfile = name
+	profileMutex.Unlock()
+	return nil
+}
+
+// DeleteProfile はプロファイルのアカウント情報とトークンを削除する
+// 既定のプロファイルと使用中のプロファイルは削除できない
+func DeleteProfile(name string) error {
+	if name == DefaultProfile || name == CurrentProfile() {
+		return errors.New("既定のプロファイルと使用中のプロファイルは削除できません")
+	}
+
+	// 保管庫を開けない場合は、トークンだけが残らないように何も消さない
+	if err := deleteProfileToken(name); err != nil {
+		return err
+	}
+
+	cfg, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	cfg.DeleteSection(profileSection(name))
+	return saveAccounts(cfg)
+}
+
+// ListProfiles は accounts.ini にあるプロファイルの名前を返す（既定のプロファイルが先頭）
+func ListProfiles() []string {
+	names := []string{DefaultProfile}
+	cfg, err := loadAccounts()
+	if err != nil {
+		return names
+	}
+
+	var others []string
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), accountSection+".")
+		if ok && ValidateProfileName(name) == nil && name != DefaultProfile {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// LoadProfile はプロファイルの情報を読み取る
+func LoadProfile(name string) (Profile, error) {
+	cfg, err := loadAccounts()
+	if err != nil {
+		return Profile{Name: name}, err
+	}
+
+	section := cfg.Section(profileSection(name))
+	return Profile{
+		Name:      name,
+		Email:     section.Key("Email").String(),
+		Plan:      section.Key("Plan").String(),
+		Bandwidth: section.Key("Bandwidth").String(),
+		ExpireAt:  section.Key("ExpireAt").String(),
+		LocalIP:   section.Key("LocalIP").String(),
+		LocalPort: section.Key("LocalPort").MustInt(0),
+	}, nil
+}
+
+// profileSection はプロファイルを保存する accounts.ini のセクション名を返す
+// 既定のプロファイルは以前のバージョンと同じ [Account] を使う
+func profileSection(name string) string {
+	if name == DefaultProfile {
+		return accountSection
+	}
+	return accountSection + "." + name
+}
+
+// profileTokenKey はプロファイルのトークンを保管庫に保存する名前を返す
+func profileTokenKey(name string) string {
+	if name == DefaultProfile {
+		return tokenVaultKey
+	}
+	return tokenVaultKey + "." + name
+}
+
+// SocketPath は使用中のプロファイルの制御APIのソケットを返す
+// プロファイルごとに別のプロセスで同時に公開できる
+func SocketPath() string {
+	name := CurrentProfile()
+	if name == DefaultProfile {
+		return control.DefaultSocketPath()
+	}
+	return control.ProfileSocketPath(name)
+}
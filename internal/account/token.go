@@ -0,0 +1,106 @@
+package account
+
+import (
+	"QuickPort/internal/statedir"
+	"QuickPort/internal/vault"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// 保管庫にトークンを保存する名前（既定のプロファイル）
+const tokenVaultKey = "token"
+
+// ReadToken は保管庫から使用中のプロファイルのトークンを読み取ります
+// 以前のバージョンが平文で保存したトークンファイルがあれば、保管庫へ移してから消します
+func ReadToken() (string, error) {
+	v, err := vault.Session()
+	if err != nil {
+		return "", err
+	}
+	profile := CurrentProfile()
+	if token, ok := v.Get(profileTokenKey(profile)); ok {
+		return token, nil
+	}
+	if profile != DefaultProfile {
+		return "", fmt.Errorf("プロファイル %s のトークンがありません", profile)
+	}
+
+	filePath := statedir.Path(statedir.TokenFile)
+	// ファイルが存在するか確認
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		log.Printf("トークンが保存されていません: %s", filePath)
+		return "", err
+	}
+
+	// ファイルを読み取る
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Printf("ファイルの読み取りに失敗しました: %v", err)
+		return "", err
+	}
+
+	token := string(data)
+	if err := WriteToken(token); err != nil {
+		log.Printf("トークンを保管庫へ移せませんでした: %v", err)
+		return token, nil
+	}
+	log.Printf("Moved %s into the vault", filePath)
+	return token, nil
+}
+
+// WriteToken は使用中のプロファイルのトークンを保管庫に暗号化して保存する
+// 平文のトークンファイルが残っていれば消す
+func WriteToken(token string) error {
+	v, err := vault.Session()
+	if err != nil {
+		return err
+	}
+	profile := CurrentProfile()
+	if err := v.Set(profileTokenKey(profile), token); err != nil {
+		return err
+	}
+	if profile != DefaultProfile {
+		return nil
+	}
+
+	filePath := statedir.Path(statedir.TokenFile)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove %s: %v", filePath, err)
+	}
+	return nil
+}
+
+// deleteProfileToken はプロファイルのトークンを保管庫から削除する
+func deleteProfileToken(name string) error {
+	v, err := vault.Session()
+	if err != nil {
+		return err
+	}
+	return v.Delete(profileTokenKey(name))
+}
+
+// MaskToken はトークンの先頭と末尾だけを残して伏せた文字列を返す
+func MaskToken(token string) string {
+	if len(token) < 10 {
+		// トークンが10文字未満の場合はそのまま返す
+		return token
+	}
+
+	// トークンの先頭8文字と末尾4文字を表示し、中間をマスク
+	if len(token) <= 16 {
+		prefix := token[:4]
+		suffix := token[len(token)-4:]
+		masked := strings.Repeat("*", len(token)-8)
+		return prefix + masked + suffix
+	}
+
+	// 長いトークンの場合
+	prefix := token[:8]              // 最初の8文字
+	suffix := token[len(token)-4:]   // 最後の4文字
+	masked := strings.Repeat("*", 16) // 中間16文字を'*'に置き換え
+
+	// マスクされたトークンを返す
+	return prefix + masked + suffix
+}
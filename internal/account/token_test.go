@@ -0,0 +1,53 @@
+package account
+
+import (
+	"QuickPort/internal/statedir"
+	"QuickPort/internal/vault"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// accounts.ini と保管庫をテスト用の一時ディレクトリに置く
+	dir, err := os.MkdirTemp("", "quickport-account")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(statedir.DirEnv, dir)
+	os.Setenv(vault.PassphraseEnv, "correct horse battery")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 以前のバージョンが平文で保存したトークンを保管庫へ移し、平文のファイルを消すこと
+func TestReadTokenMigratesPlaintext(t *testing.T) {
+	tokenPath := statedir.Path(statedir.TokenFile)
+	if err := statedir.WriteFile(tokenPath, []byte("legacy-token"), statedir.SecretPerm); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := ReadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "legacy-token" {
+		t.Fatalf("token = %q, want legacy-token", token)
+	}
+	if _, err := os.Stat(tokenPath); !os.IsNotExist(err) {
+		t.Fatalf("plaintext token file was not removed: %v", err)
+	}
+
+	v, err := vault.Open(vault.DefaultPath(), "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := v.Get(tokenVaultKey); stored != "legacy-token" {
+		t.Fatalf("vault token = %q, want legacy-token", stored)
+	}
+
+	// 移した後は保管庫から読む
+	if token, err := ReadToken(); err != nil || token != "legacy-token" {
+		t.Fatalf("ReadToken after migration = %q, %v", token, err)
+	}
+}
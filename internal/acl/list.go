@@ -0,0 +1,151 @@
+package acl
+
+import (
+	"QuickPort/internal/statedir"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 設定ファイルの名前
+const FileName = "acl.txt"
+
+// DefaultPath は状態ディレクトリにある既定の設定ファイルのパスを返す
+func DefaultPath() string {
+	return statedir.Path(FileName)
+}
+
+// ファイルの変更を確認する間隔
+const watchInterval = 2 * time.Second
+
+// ファイルを新しく作るときの内容
+const Template = `# アクセス制限の設定（1行に1つ、# 以降はコメント）
+#
+# deny  ip     198.51.100.0/24   このアドレスからの接続を拒否する
+# allow ip     203.0.113.5       allow ip が1つでもあれば、それ以外のアドレスを拒否する
+# deny  player Griefer123        このプレイヤーのログインを拒否する
+# allow player Steve             allow player が1つでもあれば、それ以外のプレイヤーを拒否する
+#
+# deny は allow より優先されます
+`
+
+// List はファイルから読み込んだ条件を保持し、ファイルが変わったら読み込み直す
+type List struct {
+	path  string
+	rules atomic.Pointer[Rules]
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewList はpathの条件を読み込む。ファイルが無い場合は条件なし（すべて許可）で始める
+// 内容に誤りがある場合もエラーと一緒にListを返し、直されるまで条件なしで動く
+func NewList(path string) (*List, error) {
+	l := &List{path: path}
+	l.rules.Store(&Rules{})
+	if err := l.Reload(); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// Path は設定ファイルのパスを返す
+func (l *List) Path() string {
+	return l.path
+}
+
+// Rules は現在の条件を返す（呼び出し側で変更しないこと）
+func (l *List) Rules() *Rules {
+	return l.rules.Load()
+}
+
+// Reload はファイルを読み込み直す
+// 内容に誤りがある場合は、それまでの条件をそのまま使い続ける
+func (l *List) Reload() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	info, err := os.Stat(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		l.rules.Store(&Rules{})
+		l.modTime, l.size = time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	// 誤りがあっても同じ内容を何度も読み込まないように記録しておく
+	l.modTime, l.size = info.ModTime(), info.Size()
+
+	rules, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	l.rules.Store(&rules)
+	return nil
+}
+
+// changed はファイルが前回の読み込みから変わったかを返す
+func (l *List) changed() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		// 削除された場合は条件を外す
+		return !l.modTime.IsZero()
+	}
+	return !info.ModTime().Equal(l.modTime) || info.Size() != l.size
+}
+
+// Watch はstopが閉じられるまでファイルの変更を監視し、変わったら読み込み直す
+func (l *List) Watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !l.changed() {
+				continue
+			}
+			if err := l.Reload(); err != nil {
+				log.Printf("Failed to reload %s: %v", l.path, err)
+				continue
+			}
+			log.Printf("Reloaded access list from %s", l.path)
+		}
+	}
+}
+
+// Save は内容を確かめてからファイルに書き込む
+func Save(path, text string) error {
+	if _, err := Parse(text); err != nil {
+		return err
+	}
+	return statedir.WriteFile(path, []byte(text), statedir.FilePerm)
+}
+
+// ReadText はファイルの内容を返す。ファイルが無い場合はひな形を返す
+func ReadText(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Template, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,160 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// 接続を許可・拒否する条件
+//
+// 拒否の条件が許可の条件より優先される。許可の条件が1つでもあれば、
+// それに当てはまらない接続はすべて拒否する
+type Rules struct {
+	AllowIPs     []netip.Prefix
+	DenyIPs      []netip.Prefix
+	AllowPlayers []string
+	DenyPlayers  []string
+}
+
+// Parse は設定ファイルの内容を解析する
+//
+// 1行に1つ、「allow/deny」「ip/player」「値」を空白区切りで書く。# 以降はコメント
+//
+//	deny   ip     198.51.100.0/24
+//	allow  player Steve
+func Parse(text string) (Rules, error) {
+	var rules Rules
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return Rules{}, fmt.Errorf("%d行目: 「allow ip 203.0.113.0/24」の形式で書いてください", lineNumber)
+		}
+
+		action, kind, value := strings.ToLower(fields[0]), strings.ToLower(fields[1]), fields[2]
+		if action != "allow" && action != "deny" {
+			return Rules{}, fmt.Errorf("%d行目: allow か deny を指定してください: %s", lineNumber, fields[0])
+		}
+
+		switch kind {
+		case "ip":
+			prefix, err := parsePrefix(value)
+			if err != nil {
+				return Rules{}, fmt.Errorf("%d行目: IPアドレスの形式が正しくありません: %s", lineNumber, value)
+			}
+			if action == "allow" {
+				rules.AllowIPs = append(rules.AllowIPs, prefix)
+			} else {
+				rules.DenyIPs = append(rules.DenyIPs, prefix)
+			}
+		case "player":
+			if action == "allow" {
+				rules.AllowPlayers = append(rules.AllowPlayers, value)
+			} else {
+				rules.DenyPlayers = append(rules.DenyPlayers, value)
+			}
+		default:
+			return Rules{}, fmt.Errorf("%d行目: ip か player を指定してください: %s", lineNumber, fields[1])
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// parsePrefix はCIDRか単独のIPアドレスを解析する
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// IsEmpty は条件が1つも無いかどうかを返す
+func (r *Rules) IsEmpty() bool {
+	return len(r.AllowIPs) == 0 && len(r.DenyIPs) == 0 && !r.HasPlayerRules()
+}
+
+// HasPlayerRules はプレイヤー名の条件があるかどうかを返す
+func (r *Rules) HasPlayerRules() bool {
+	return len(r.AllowPlayers) > 0 || len(r.DenyPlayers) > 0
+}
+
+// CheckAddr はプレイヤーのアドレス（"ip:port" か "ip"）が許可されているかを確かめる
+// 拒否する場合はその理由を返す
+func (r *Rules) CheckAddr(remoteAddr string) (string, bool) {
+	if len(r.AllowIPs) == 0 && len(r.DenyIPs) == 0 {
+		return "", true
+	}
+
+	addr, err := parseRemoteAddr(remoteAddr)
+	if err != nil {
+		if len(r.AllowIPs) > 0 {
+			// アドレスが分からない接続は許可リストと照合できないので通さない
+			return "unknown remote address", false
+		}
+		return "", true
+	}
+
+	for _, prefix := range r.DenyIPs {
+		if prefix.Contains(addr) {
+			return fmt.Sprintf("denied by ip rule %s", prefix), false
+		}
+	}
+	if len(r.AllowIPs) == 0 {
+		return "", true
+	}
+	for _, prefix := range r.AllowIPs {
+		if prefix.Contains(addr) {
+			return "", true
+		}
+	}
+	return "not in ip allowlist", false
+}
+
+func parseRemoteAddr(remoteAddr string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(remoteAddr); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(remoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+// CheckPlayer はプレイヤー名が許可されているかを確かめる（大文字と小文字は区別しない）
+// 拒否する場合はその理由を返す
+func (r *Rules) CheckPlayer(name string) (string, bool) {
+	for _, denied := range r.DenyPlayers {
+		if strings.EqualFold(denied, name) {
+			return fmt.Sprintf("denied by player rule %s", denied), false
+		}
+	}
+	if len(r.AllowPlayers) == 0 {
+		return "", true
+	}
+	for _, allowed := range r.AllowPlayers {
+		if strings.EqualFold(allowed, name) {
+			return "", true
+		}
+	}
+	return "not in player allowlist", false
+}
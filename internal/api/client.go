@@ -0,0 +1,169 @@
+// Package api はQuickPortの認証API（アカウント登録・トークン発行）のクライアント
+//
+// リクエストと応答は型付きのモデルで扱い、サーバが status: ERROR を返した場合は *Error を返す。
+// 何度送っても結果が変わらない呼び出し（GET）は、通信エラーやサーバの一時的なエラーのときに再試行する
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 既定値
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultRetries   = 2
+	defaultRetryWait = 500 * time.Millisecond
+)
+
+// 応答を読み取る上限
+const maxResponseSize = 1 << 20
+
+// Options はクライアントの設定（ゼロ値の項目は既定値を使う）
+type Options struct {
+	Timeout    time.Duration // 1回のリクエストのタイムアウト
+	Retries    int           // 再試行できる呼び出しを再試行する回数（負の値なら再試行しない）
+	RetryWait  time.Duration // 最初の再試行までの待ち時間（再試行のたびに2倍にする）
+	HTTPClient *http.Client  // 指定した場合は Timeout より優先する
+}
+
+// Client は認証APIのクライアント
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	retryWait  time.Duration
+}
+
+// New はbaseURLの認証APIに接続するクライアントを作る
+func New(baseURL string, options Options) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: options.HTTPClient,
+		retries:    options.Retries,
+		retryWait:  options.RetryWait,
+	}
+	if c.httpClient == nil {
+		timeout := options.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		c.httpClient = &http.Client{Timeout: timeout}
+	}
+	if c.retries == 0 {
+		c.retries = defaultRetries
+	}
+	if c.retries < 0 {
+		c.retries = 0
+	}
+	if c.retryWait <= 0 {
+		c.retryWait = defaultRetryWait
+	}
+	return c
+}
+
+// Ping は認証サーバが応答するか確かめる
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/ping", nil, nil)
+}
+
+// Signup はアカウントを登録する
+// 登録は再試行しない（1回目が届いていた場合に重複して登録しないため）
+func (c *Client) Signup(ctx context.Context, request SignupRequest) (Response, error) {
+	var response Response
+	err := c.do(ctx, http.MethodPost, "/auth/signup", request, &response)
+	return response, err
+}
+
+// IssueToken はトークンを発行する
+// 発行は再試行しない（呼び出すたびに新しいトークンを発行するため）
+func (c *Client) IssueToken(ctx context.Context, request TokenRequest) (TokenResponse, error) {
+	var response TokenResponse
+	err := c.do(ctx, http.MethodPost, "/auth/token-issuance", request, &response)
+	return response, err
+}
+
+// do はリクエストを送って応答をoutに読み取る
+// GETは通信エラーと再試行してよい応答のときに、待ち時間を倍にしながら再試行する
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("リクエストの作成に失敗しました: %w", err)
+		}
+	}
+
+	attempts := 1
+	if method == http.MethodGet {
+		attempts += c.retries
+	}
+	wait := c.retryWait
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		retryable, err = c.send(ctx, method, path, data, out)
+		if err == nil || !retryable || attempt >= attempts {
+			return err
+		}
+
+		log.Printf("API %s %s に失敗しました（%d/%d回目）。%v 後に再試行します: %v", method, path, attempt, attempts, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// send はリクエストを1回送る。失敗した場合は再試行してよいかどうかも返す
+func (c *Client) send(ctx context.Context, method, path string, data []byte, out any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("HTTPリクエストの作成に失敗しました: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// 呼び出し元が中止した場合は再試行しない
+		return ctx.Err() == nil, fmt.Errorf("HTTPリクエストの送信に失敗しました: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return true, fmt.Errorf("レスポンスボディの読み取りに失敗しました: %w", err)
+	}
+
+	// status と message はすべての応答に共通
+	var base Response
+	parseErr := json.Unmarshal(respBody, &base)
+
+	if resp.StatusCode >= http.StatusBadRequest || base.Status == STATUS_ERROR {
+		apiErr := &Error{StatusCode: resp.StatusCode, Status: base.Status, Message: base.Message}
+		return apiErr.temporary(), apiErr
+	}
+	if out == nil {
+		return false, nil
+	}
+	if parseErr != nil {
+		return false, fmt.Errorf("レスポンスボディのパースに失敗しました: %w", parseErr)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return false, fmt.Errorf("レスポンスボディのパースに失敗しました: %w", err)
+	}
+	return false, nil
+}
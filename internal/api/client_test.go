@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient はhandlerで応答するサーバと、そこへ接続するクライアントを作る
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL+"/", Options{RetryWait: time.Millisecond})
+}
+
+// writeJSON はvalueをJSONで応答する
+func writeJSON(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+// HTTPのステータスコードを種類ごとのエラーに対応付け、サーバの message をそのまま返すこと
+func TestErrorMapping(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, ErrServer},
+		{http.StatusBadGateway, ErrServer},
+	}
+	for _, tt := range tests {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, tt.statusCode, Response{Status: STATUS_ERROR, Message: "server message"})
+		})
+
+		_, err := client.Signup(context.Background(), SignupRequest{Email: "owner@example.com", Password: "secret"})
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("HTTP %d: err = %v, want %v", tt.statusCode, err, tt.want)
+		}
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.statusCode || apiErr.Message != "server message" {
+			t.Fatalf("HTTP %d: unexpected error %#v", tt.statusCode, err)
+		}
+		if err.Error() != "server message" {
+			t.Fatalf("HTTP %d: Error() = %q", tt.statusCode, err.Error())
+		}
+	}
+}
+
+// HTTP 200 でも status: ERROR の応答はエラーにすること
+func TestStatusErrorWithOK(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Response{Status: STATUS_ERROR, Message: "plan limit reached"})
+	})
+
+	_, err := client.IssueToken(context.Background(), TokenRequest{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.Status != STATUS_ERROR || apiErr.Message != "plan limit reached" {
+		t.Fatalf("unexpected error %#v", apiErr)
+	}
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrConflict) || errors.Is(err, ErrServer) {
+		t.Fatalf("HTTP 200 error matched a status code error: %v", err)
+	}
+}
+
+// JSONでない応答のエラーはステータスコードを含むメッセージにすること
+func TestErrorWithoutJSON(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	_, err := client.Signup(context.Background(), SignupRequest{})
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("err = %v, want ErrServer", err)
+	}
+	if err.Error() != "認証APIがエラーを返しました (HTTP 502)" {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+}
+
+// GETはサーバの一時的なエラーのときに再試行し、成功したらエラーを返さないこと
+func TestGetRetriesTemporaryError(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= defaultRetries {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, Response{Status: STATUS_OK})
+	})
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := attempts.Load(); n != defaultRetries+1 {
+		t.Fatalf("attempts = %d, want %d", n, defaultRetries+1)
+	}
+}
+
+// 再試行しても直らないエラーは再試行の回数で諦め、再試行してはいけないエラーは1回で返すこと
+func TestGetRetryLimit(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       int32
+	}{
+		{http.StatusServiceUnavailable, defaultRetries + 1},
+		{http.StatusTooManyRequests, defaultRetries + 1},
+		{http.StatusBadRequest, 1},
+		{http.StatusUnauthorized, 1},
+	}
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(tt.statusCode)
+		})
+
+		if err := client.Ping(context.Background()); err == nil {
+			t.Fatalf("HTTP %d: Ping succeeded", tt.statusCode)
+		}
+		if n := attempts.Load(); n != tt.want {
+			t.Fatalf("HTTP %d: attempts = %d, want %d", tt.statusCode, n, tt.want)
+		}
+	}
+}
+
+// 登録とトークン発行（POST）は一時的なエラーでも再試行しないこと
+func TestPostIsNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if _, err := client.Signup(context.Background(), SignupRequest{}); !errors.Is(err, ErrServer) {
+		t.Fatalf("Signup err = %v, want ErrServer", err)
+	}
+	if _, err := client.IssueToken(context.Background(), TokenRequest{}); !errors.Is(err, ErrServer) {
+		t.Fatalf("IssueToken err = %v, want ErrServer", err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("attempts = %d, want 2", n)
+	}
+}
+
+// 呼び出し元が中止したら再試行を待たずに返すこと
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := New(server.URL, Options{RetryWait: time.Minute})
+
+	done := make(chan error, 1)
+	go func() { done <- client.Ping(ctx) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Ping succeeded after cancel")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Ping kept waiting to retry after cancel")
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("attempts = %d, want 1", n)
+	}
+}
+
+// トークン発行のリクエストを決まったJSONで送り、応答の項目を読み取ること
+func TestIssueTokenRequestAndResponse(t *testing.T) {
+	var body map[string]map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/auth/token-issuance" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"status":          STATUS_OK,
+			"message":         "issued",
+			"token":           "new-token",
+			"email":           "owner@example.com",
+			"plan":            "Free",
+			"bandwidth_limit": "10Mbps",
+			"expire_at":       "2027-07-20T21:04:44+09:00",
+		})
+	})
+
+	response, err := client.IssueToken(context.Background(), TokenRequest{
+		Metadata: TokenMetadata{LocalIP: "127.0.0.1", LocalPort: 25565, ProtocolType: "tcp"},
+		UserInfo: UserInfo{Email: "owner@example.com", Password: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := body["request_token_metadata"]
+	if metadata["local_ip"] != "127.0.0.1" || metadata["local_port"] != float64(25565) || metadata["protocol_type"] != "tcp" {
+		t.Fatalf("request_token_metadata = %v", metadata)
+	}
+	userInfo := body["request_user_info"]
+	if userInfo["email"] != "owner@example.com" || userInfo["password"] != "secret" {
+		t.Fatalf("request_user_info = %v", userInfo)
+	}
+	if _, ok := userInfo["user_name"]; ok {
+		t.Fatalf("empty user_name was sent: %v", userInfo)
+	}
+
+	want := TokenResponse{
+		Message:   "issued",
+		Status:    STATUS_OK,
+		Token:     "new-token",
+		Email:     "owner@example.com",
+		Plan:      "Free",
+		Bandwidth: "10Mbps",
+		ExpireAt:  "2027-07-20T21:04:44+09:00",
+	}
+	if response != want {
+		t.Fatalf("response = %+v, want %+v", response, want)
+	}
+}
+
+// 登録のリクエストを決まったJSONで送り、応答の message を読み取ること
+func TestSignupRequestAndResponse(t *testing.T) {
+	var body SignupRequest
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/auth/signup" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		writeJSON(w, http.StatusOK, Response{Status: STATUS_OK, Message: "registered"})
+	})
+
+	response, err := client.Signup(context.Background(), SignupRequest{Email: "owner@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Email != "owner@example.com" || body.Password != "secret" {
+		t.Fatalf("request = %+v", body)
+	}
+	if response.Status != STATUS_OK || response.Message != "registered" {
+		t.Fatalf("response = %+v", response)
+	}
+}
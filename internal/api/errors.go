@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errors.Is で Error の種類を確かめるためのエラー
+var (
+	ErrUnauthorized = errors.New("メールアドレスかパスワードが違います") // 401 / 403
+	ErrConflict     = errors.New("すでに登録されています")        // 409
+	ErrServer       = errors.New("認証サーバでエラーが発生しました")   // 5xx
+)
+
+// Error は認証APIが返したエラー
+// Message にはサーバの message をそのまま入れる
+type Error struct {
+	StatusCode int    // HTTPのステータスコード
+	Status     string // 応答の status（JSONでない応答では空）
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("認証APIがエラーを返しました (HTTP %d)", e.StatusCode)
+}
+
+// Is はHTTPのステータスコードから ErrUnauthorized などに当てはまるかを返す
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// temporary は時間をおいて送り直せば成功するかもしれないエラーかどうかを返す
+func (e *Error) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,50 @@
+package api
+
+// 応答の status
+const (
+	STATUS_OK    = "OK"
+	STATUS_ERROR = "ERROR"
+)
+
+// Response はすべての応答に共通する項目
+type Response struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// SignupRequest はアカウント登録のリクエスト
+type SignupRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// TokenRequest はトークン発行のリクエスト
+type TokenRequest struct {
+	Metadata TokenMetadata `json:"request_token_metadata"`
+	UserInfo UserInfo      `json:"request_user_info"`
+}
+
+// TokenMetadata はトークンに登録する転送先
+type TokenMetadata struct {
+	LocalIP      string `json:"local_ip"`
+	LocalPort    int    `json:"local_port"`
+	ProtocolType string `json:"protocol_type"` // tcp か udp
+}
+
+// UserInfo はトークンを発行するアカウント
+type UserInfo struct {
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password"`
+	UserName string `json:"user_name,omitempty"`
+}
+
+// TokenResponse はトークン発行の応答
+type TokenResponse struct {
+	Message   string `json:"message"`
+	Status    string `json:"status"`
+	Token     string `json:"token"`
+	Email     string `json:"email,omitempty"`
+	Plan      string `json:"plan,omitempty"`
+	Bandwidth string `json:"bandwidth_limit,omitempty"`
+	ExpireAt  string `json:"expire_at,omitempty"`
+}
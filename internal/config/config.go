@@ -0,0 +1,245 @@
+// Package config は接続先やタイムアウトなどの動作設定を読み込む
+//
+// 設定は 既定値 < 設定ファイル(YAML) < 環境変数(QUICKPORT_*) < コマンドライン引数 の順に上書きする。
+// 自前のリレーや検証用のリレー、テスト用の偽のサーバへ向けるときに使う
+package config
+
+import (
+	"QuickPort/internal/statedir"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 設定ファイルの名前
+const FileName = "quickport.yaml"
+
+// DefaultPath は状態ディレクトリにある既定の設定ファイルのパスを返す
+func DefaultPath() string {
+	return statedir.Path(FileName)
+}
+
+// 設定ファイルの場所を指定する環境変数
+const PathEnv = "QUICKPORT_CONFIG"
+
+// Config はQuickPortの動作設定
+type Config struct {
+	Relay      RelayConfig      `yaml:"relay"`
+	API        APIConfig        `yaml:"api"`
+	Local      LocalConfig      `yaml:"local"`
+	Proxies    []ProxyConfig    `yaml:"proxies"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Bandwidth  BandwidthConfig  `yaml:"bandwidth"`
+	Offline    OfflineConfig    `yaml:"offline"`
+	WakeOnJoin WakeOnJoinConfig `yaml:"wake_on_join"`
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+	Log        LogConfig        `yaml:"log"`
+}
+
+// RelayConfig は接続するリレーサーバ
+type RelayConfig struct {
+	Addr       string `yaml:"addr"`        // 制御コネクションの接続先（host:port）
+	PublicHost string `yaml:"public_host"` // プレイヤーが接続するホスト名（公開アドレスの表示に使う）
+	TLS        bool   `yaml:"tls"`         // 制御コネクションをTLSで暗号化する
+	ServerName string `yaml:"server_name"` // 証明書の検証に使うホスト名（空なら addr のホスト部）
+	// サーバ公開鍵のSHA-256ハッシュ(base64)。指定するとシステムのルート証明書の代わりにこの値で検証する
+	PinnedSPKI string `yaml:"pinned_spki"`
+}
+
+// APIConfig は認証APIなどのHTTPの接続先
+type APIConfig struct {
+	BaseURL         string `yaml:"base_url"`         // アカウント登録・トークン発行のAPI
+	ReleaseEndpoint string `yaml:"release_endpoint"` // 最新版を確認するGitHubのAPI
+	WebsiteURL      string `yaml:"website_url"`      // お知らせを取得するWebサイト
+}
+
+// LocalConfig はプレイヤーの接続を転送するローカルサーバ
+// 空の項目はトークンに登録した転送先を使う
+type LocalConfig struct {
+	IP   string `yaml:"ip"`
+	Port int    `yaml:"port"`
+}
+
+// ProxyConfig はトークンの設定に加えて公開するプロキシ
+// トークンで公開しているプロキシ（名前はプロトコル名と同じ）を書くと、転送先などを上書きする
+//
+//	proxies:
+//	  - name: dynmap
+//	    local_port: 8123
+//	  - name: tcp
+//	    proxy_protocol: v2
+type ProxyConfig struct {
+	Name          string `yaml:"name"`
+	Type          string `yaml:"type"` // tcp か udp（空なら tcp）
+	LocalIP       string `yaml:"local_ip"`
+	LocalPort     int    `yaml:"local_port"`
+	RemotePort    int    `yaml:"remote_port"`
+	ProxyProtocol string `yaml:"proxy_protocol"` // v1 か v2 でローカル接続の先頭にPROXYプロトコルのヘッダを付ける
+	Minecraft     bool   `yaml:"minecraft"`      // プレイヤー名による制限やオフライン応答の対象にする
+}
+
+// RateLimitConfig は新しい接続の受け入れ制限
+// 0の項目は既定値を使い、負の値でその制限を外す。IPアドレスごとの制限（per_ip_*）は指定した場合だけ使う
+type RateLimitConfig struct {
+	Rate            float64 `yaml:"rate"`               // 全体で1秒あたりに受け入れる新しい接続の数
+	Burst           int     `yaml:"burst"`              // 全体で一度に受け入れられる新しい接続の数
+	PerIPRate       float64 `yaml:"per_ip_rate"`        // 同じIPアドレスから1秒あたりに受け入れる新しい接続の数
+	PerIPBurst      int     `yaml:"per_ip_burst"`       // 同じIPアドレスから一度に受け入れられる新しい接続の数
+	MaxStreams      int     `yaml:"max_streams"`        // 同時に転送する接続の上限
+	MaxStreamsPerIP int     `yaml:"max_streams_per_ip"` // 同じIPアドレスから同時に転送する接続の上限
+	AttackThreshold int     `yaml:"attack_threshold"`   // 10秒以内にこの数を拒否したら攻撃を受けているとみなす
+}
+
+// BandwidthConfig は転送速度の制限
+type BandwidthConfig struct {
+	Shape bool   `yaml:"shape"` // トークンの帯域（limit があればそちら）に合わせて転送速度を抑える
+	Limit string `yaml:"limit"` // 帯域の上書き（例: 10Mbps）
+}
+
+// OfflineConfig はローカルサーバが停止中のときにサーバーリストとログインへ返す内容
+// 空の項目は既定の文言を使う
+type OfflineConfig struct {
+	Enabled           bool   `yaml:"enabled"` // 無効なら従来どおり接続を切る
+	MOTD              string `yaml:"motd"`
+	Favicon           string `yaml:"favicon"` // サーバーアイコンのPNGファイル
+	VersionName       string `yaml:"version_name"`
+	MaxPlayers        int    `yaml:"max_players"`
+	OnlinePlayers     int    `yaml:"online_players"`
+	DisconnectMessage string `yaml:"disconnect_message"`
+	StartingMOTD      string `yaml:"starting_motd"`    // wake_on_join でサーバを起動している間の説明文
+	StartingMessage   string `yaml:"starting_message"` // wake_on_join でサーバを起動している間の切断理由
+}
+
+// WakeOnJoinConfig は最初のプレイヤーがログインしようとしたときに起動するローカルサーバ
+// command が空なら起動しない。idle_timeout の間だれも接続していなければ stop_command を送って停止する
+type WakeOnJoinConfig struct {
+	Command      string        `yaml:"command"` // 例: java -Xmx2G -jar paper.jar nogui
+	Dir          string        `yaml:"dir"`     // 作業ディレクトリ
+	Addr         string        `yaml:"addr"`    // 起動完了を確認するアドレス（空なら転送先）
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	StartTimeout time.Duration `yaml:"start_timeout"`
+	StopCommand  string        `yaml:"stop_command"`
+}
+
+// TimeoutsConfig は各種のタイムアウト（0なら既定値）
+type TimeoutsConfig struct {
+	Dial              time.Duration `yaml:"dial"`               // リレーへの接続
+	API               time.Duration `yaml:"api"`                // APIへのHTTPリクエスト
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"` // リレーへの生存確認の間隔
+	HeartbeatTimeout  time.Duration `yaml:"heartbeat_timeout"`  // リレーからの応答が途絶えたとみなす時間
+	UDPIdle           time.Duration `yaml:"udp_idle"`           // UDPセッションを閉じるまでの無通信時間
+}
+
+// LogConfig はログの出力先
+type LogConfig struct {
+	Enabled bool   `yaml:"enabled"` // ファイルへ書き出す（--log と同じ）
+	File    string `yaml:"file"`
+}
+
+// Default は設定ファイルが無い場合の設定を返す
+func Default() Config {
+	return Config{
+		Relay: RelayConfig{
+			Addr:       "163.44.96.225:5555",
+			PublicHost: "quickport.natyosu.com",
+		},
+		API: APIConfig{
+			BaseURL:         "https://qp-auth-api-v2.natyosu.com",
+			ReleaseEndpoint: "https://api.github.com/repos/natyosu3/QuickPort/releases/latest",
+			WebsiteURL:      "https://qp.natyosu.com/",
+		},
+		WakeOnJoin: WakeOnJoinConfig{
+			IdleTimeout:  10 * time.Minute,
+			StartTimeout: 5 * time.Minute,
+			StopCommand:  "stop",
+		},
+		Timeouts: TimeoutsConfig{
+			Dial: 10 * time.Second,
+			API:  10 * time.Second,
+		},
+		Log: LogConfig{
+			File: statedir.Path(statedir.LogFile),
+		},
+	}
+}
+
+// 起動時に読み込んだ設定
+// 既定値は状態ディレクトリのパスを含むので、パッケージの初期化では作らずに Get で作る
+var (
+	currentMutex sync.RWMutex
+	current      *Config
+)
+
+// Get は起動時に読み込んだ設定を返す（読み込む前は既定値）
+func Get() Config {
+	currentMutex.RLock()
+	defer currentMutex.RUnlock()
+	if current == nil {
+		return Default()
+	}
+	return *current
+}
+
+// Set は以降 Get が返す設定を置き換える
+func Set(cfg Config) {
+	currentMutex.Lock()
+	defer currentMutex.Unlock()
+	current = &cfg
+}
+
+// Load は既定値に設定ファイル・環境変数・overridesの順に重ねた設定を返す
+// pathが空の場合は QUICKPORT_CONFIG か既定の設定ファイルを読み、ファイルが無ければ既定値から始める。
+// overridesはコマンドライン引数で指定された「キー → 値」（キーは relay.addr の形式）
+func Load(path string, overrides map[string]string) (Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(PathEnv)
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultPath()
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			if key := invalidKey(data); key != "" {
+				return cfg, fmt.Errorf("%s を読み取れません: %s: %w", path, key, err)
+			}
+			return cfg, fmt.Errorf("%s を読み取れません: %w", path, err)
+		}
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+		// 設定ファイルは無くてもよい
+	default:
+		return cfg, err
+	}
+
+	for _, field := range fields(&cfg) {
+		value, ok := os.LookupEnv(field.env())
+		if !ok {
+			continue
+		}
+		if err := field.set(value); err != nil {
+			return cfg, fmt.Errorf("環境変数 %s: %w", field.env(), err)
+		}
+	}
+
+	for _, field := range fields(&cfg) {
+		value, ok := overrides[field.key]
+		if !ok {
+			continue
+		}
+		if err := field.set(value); err != nil {
+			return cfg, fmt.Errorf("--%s: %w", field.flag(), err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"QuickPort/internal/statedir"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// 既定の設定ファイルをテスト用の一時ディレクトリに置く
+	dir, err := os.MkdirTemp("", "quickport-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(statedir.DirEnv, dir)
+	os.Unsetenv(PathEnv)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeConfig は設定ファイルを書き、そのパスを返す
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// parseFlags はコマンドライン引数を RegisterFlags で読み、Load に渡す overrides を返す
+func parseFlags(t *testing.T, args ...string) map[string]string {
+	t.Helper()
+	overrides := make(map[string]string)
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	RegisterFlags(flags, overrides)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return overrides
+}
+
+// 既定の設定ファイルが無ければ既定値を使い、指定したファイルが無ければエラーにすること
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Fatalf("config without a file = %+v, want defaults", cfg)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), FileName), nil); err == nil {
+		t.Fatal("missing explicit config file was ignored")
+	}
+	t.Setenv(PathEnv, filepath.Join(t.TempDir(), FileName))
+	if _, err := Load("", nil); err == nil {
+		t.Fatalf("missing %s file was ignored", PathEnv)
+	}
+}
+
+// 既定値 < 設定ファイル < 環境変数 < コマンドライン引数 の順に上書きすること
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+relay:
+  addr: file.example.com:5555
+  public_host: file.example.com
+local:
+  port: 1
+timeouts:
+  dial: 1s
+`)
+	t.Setenv("QUICKPORT_RELAY_PUBLIC_HOST", "env.example.com")
+	t.Setenv("QUICKPORT_LOCAL_PORT", "2")
+	overrides := parseFlags(t, "--local-port", "3", "--log-enabled")
+
+	cfg, err := Load(path, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Relay.Addr != "file.example.com:5555" {
+		t.Errorf("relay.addr = %q, want the file value", cfg.Relay.Addr)
+	}
+	if cfg.Relay.PublicHost != "env.example.com" {
+		t.Errorf("relay.public_host = %q, want the env value", cfg.Relay.PublicHost)
+	}
+	if cfg.Local.Port != 3 {
+		t.Errorf("local.port = %d, want the flag value", cfg.Local.Port)
+	}
+	if cfg.Timeouts.Dial != time.Second {
+		t.Errorf("timeouts.dial = %v, want the file value", cfg.Timeouts.Dial)
+	}
+	if !cfg.Log.Enabled {
+		t.Error("log.enabled = false, want true from a bare bool flag")
+	}
+	if cfg.API.BaseURL != Default().API.BaseURL {
+		t.Errorf("api.base_url = %q, want the default", cfg.API.BaseURL)
+	}
+}
+
+// 設定ファイルの型が合わない項目は、そのキーを含むエラーにすること
+func TestLoadFileErrorNamesKey(t *testing.T) {
+	tests := []struct {
+		data string
+		key  string
+	}{
+		{"timeouts:\n  dial: soon\n", "timeouts.dial"},
+		{"local:\n  port: abc\n", "local.port"},
+		{"offline:\n  enabled: maybe\n", "offline.enabled"},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.data), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%q: err = %v, want it to name %s", tt.data, err, tt.key)
+		}
+	}
+}
+
+// 環境変数とコマンドライン引数の型が合わない場合は、その名前を含むエラーにすること
+func TestLoadOverrideErrorNamesKey(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("QUICKPORT_TIMEOUTS_DIAL", "soon")
+		_, err := Load("", nil)
+		if err == nil || !strings.Contains(err.Error(), "QUICKPORT_TIMEOUTS_DIAL") {
+			t.Fatalf("err = %v, want it to name QUICKPORT_TIMEOUTS_DIAL", err)
+		}
+	})
+	t.Run("env int", func(t *testing.T) {
+		t.Setenv("QUICKPORT_LOCAL_PORT", "abc")
+		_, err := Load("", nil)
+		if err == nil || !strings.Contains(err.Error(), "QUICKPORT_LOCAL_PORT") {
+			t.Fatalf("err = %v, want it to name QUICKPORT_LOCAL_PORT", err)
+		}
+	})
+	t.Run("flag", func(t *testing.T) {
+		_, err := Load("", parseFlags(t, "--rate-limit-rate", "fast"))
+		if err == nil || !strings.Contains(err.Error(), "--rate-limit-rate") {
+			t.Fatalf("err = %v, want it to name --rate-limit-rate", err)
+		}
+	})
+}
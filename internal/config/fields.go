@@ -0,0 +1,178 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// field は環境変数とコマンドライン引数で上書きできる設定項目
+type field struct {
+	key   string // 設定ファイルでの位置（relay.addr の形式）
+	usage string
+	value any // *string / *int / *float64 / *bool / *time.Duration
+}
+
+// env は上書きに使う環境変数名（relay.public_host → QUICKPORT_RELAY_PUBLIC_HOST）
+func (f field) env() string {
+	return "QUICKPORT_" + strings.ToUpper(strings.ReplaceAll(f.key, ".", "_"))
+}
+
+// flag は上書きに使うコマンドライン引数名（relay.public_host → relay-public-host）
+func (f field) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+func (f field) set(value string) error {
+	switch p := f.value.(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("数値を指定してください: %q", value)
+		}
+		*p = n
+	case *float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("数値を指定してください: %q", value)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("true か false を指定してください: %q", value)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("10s や 1m のような時間を指定してください: %q", value)
+		}
+		*p = d
+	}
+	return nil
+}
+
+func fields(cfg *Config) []field {
+	return []field{
+		{"relay.addr", "リレーサーバの接続先（host:port）", &cfg.Relay.Addr},
+		{"relay.public_host", "プレイヤーが接続するホスト名", &cfg.Relay.PublicHost},
+		{"relay.tls", "リレーへの接続をTLSで暗号化する", &cfg.Relay.TLS},
+		{"relay.server_name", "リレーの証明書の検証に使うホスト名", &cfg.Relay.ServerName},
+		{"relay.pinned_spki", "リレーの公開鍵のSHA-256ハッシュ(base64)", &cfg.Relay.PinnedSPKI},
+		{"api.base_url", "認証APIのURL", &cfg.API.BaseURL},
+		{"api.release_endpoint", "最新版を確認するAPIのURL", &cfg.API.ReleaseEndpoint},
+		{"api.website_url", "お知らせを取得するWebサイトのURL", &cfg.API.WebsiteURL},
+		{"local.ip", "転送先のローカルサーバのIPアドレス（空ならトークンの設定）", &cfg.Local.IP},
+		{"local.port", "転送先のローカルサーバのポート（0ならトークンの設定）", &cfg.Local.Port},
+		{"rate_limit.rate", "1秒あたりに受け入れる新しい接続の数", &cfg.RateLimit.Rate},
+		{"rate_limit.burst", "一度に受け入れられる新しい接続の数", &cfg.RateLimit.Burst},
+		{"rate_limit.per_ip_rate", "同じIPアドレスから1秒あたりに受け入れる新しい接続の数（0なら制限しない）", &cfg.RateLimit.PerIPRate},
+		{"rate_limit.per_ip_burst", "同じIPアドレスから一度に受け入れられる新しい接続の数", &cfg.RateLimit.PerIPBurst},
+		{"rate_limit.max_streams", "同時に転送する接続の上限", &cfg.RateLimit.MaxStreams},
+		{"rate_limit.max_streams_per_ip", "同じIPアドレスから同時に転送する接続の上限（0なら制限しない）", &cfg.RateLimit.MaxStreamsPerIP},
+		{"rate_limit.attack_threshold", "攻撃を受けているとみなす10秒あたりの拒否数", &cfg.RateLimit.AttackThreshold},
+		{"bandwidth.shape", "プランの帯域に合わせて転送速度を抑える", &cfg.Bandwidth.Shape},
+		{"bandwidth.limit", "帯域の上書き（例: 10Mbps）", &cfg.Bandwidth.Limit},
+		{"offline.enabled", "ローカルサーバの停止中にサーバーリストとログインへ応答する", &cfg.Offline.Enabled},
+		{"offline.motd", "停止中にサーバーリストへ表示する説明文", &cfg.Offline.MOTD},
+		{"offline.favicon", "停止中に表示するサーバーアイコン（PNG）", &cfg.Offline.Favicon},
+		{"offline.version_name", "停止中にサーバーリストへ表示するバージョン名", &cfg.Offline.VersionName},
+		{"offline.max_players", "停止中にサーバーリストへ表示する最大人数", &cfg.Offline.MaxPlayers},
+		{"offline.online_players", "停止中にサーバーリストへ表示する接続人数", &cfg.Offline.OnlinePlayers},
+		{"offline.disconnect_message", "停止中にログインしたプレイヤーへの切断理由", &cfg.Offline.DisconnectMessage},
+		{"offline.starting_motd", "起動中にサーバーリストへ表示する説明文", &cfg.Offline.StartingMOTD},
+		{"offline.starting_message", "起動中にログインしたプレイヤーへの切断理由", &cfg.Offline.StartingMessage},
+		{"wake_on_join.command", "プレイヤーが来たときに実行するローカルサーバの起動コマンド", &cfg.WakeOnJoin.Command},
+		{"wake_on_join.dir", "ローカルサーバの作業ディレクトリ", &cfg.WakeOnJoin.Dir},
+		{"wake_on_join.addr", "起動完了を確認するアドレス（空なら転送先）", &cfg.WakeOnJoin.Addr},
+		{"wake_on_join.idle_timeout", "だれも接続していないローカルサーバを停止するまでの時間", &cfg.WakeOnJoin.IdleTimeout},
+		{"wake_on_join.start_timeout", "ローカルサーバの起動を待つ時間", &cfg.WakeOnJoin.StartTimeout},
+		{"wake_on_join.stop_command", "ローカルサーバの標準入力へ送る停止コマンド", &cfg.WakeOnJoin.StopCommand},
+		{"timeouts.dial", "リレーへの接続のタイムアウト", &cfg.Timeouts.Dial},
+		{"timeouts.api", "APIへのリクエストのタイムアウト", &cfg.Timeouts.API},
+		{"timeouts.heartbeat_interval", "リレーへの生存確認の間隔", &cfg.Timeouts.HeartbeatInterval},
+		{"timeouts.heartbeat_timeout", "リレーからの応答が途絶えたとみなす時間", &cfg.Timeouts.HeartbeatTimeout},
+		{"timeouts.udp_idle", "UDPセッションを閉じるまでの無通信時間", &cfg.Timeouts.UDPIdle},
+		{"log.enabled", "ログをファイルへ書き出す", &cfg.Log.Enabled},
+		{"log.file", "ログファイル", &cfg.Log.File},
+	}
+}
+
+// invalidKey は設定ファイルで型が合わない項目のキーを返す（見つからなければ空文字）
+// yaml のエラーは行番号しか示さないので、項目ごとに読み直して確かめる
+func invalidKey(data []byte) string {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return ""
+	}
+
+	cfg := Default()
+	for _, f := range fields(&cfg) {
+		node := lookupNode(root.Content[0], strings.Split(f.key, "."))
+		if node != nil && node.Decode(f.value) != nil {
+			return f.key
+		}
+	}
+	return ""
+}
+
+// lookupNode はマッピングをたどって path の値のノードを返す
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, name := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// RegisterFlags はすべての設定項目を --relay-addr の形式でflagsに登録する
+// 指定された値は Load に渡す overrides へ記録する
+func RegisterFlags(flags *flag.FlagSet, overrides map[string]string) {
+	defaults := Default()
+	for _, f := range fields(&defaults) {
+		_, isBool := f.value.(*bool)
+		flags.Var(&overrideValue{key: f.key, overrides: overrides, isBool: isBool}, f.flag(), f.usage)
+	}
+}
+
+// overrideValue はコマンドライン引数の値を検証せずに記録する（検証は Load で行う）
+type overrideValue struct {
+	key       string
+	overrides map[string]string
+	isBool    bool
+}
+
+func (v *overrideValue) String() string {
+	if v == nil || v.overrides == nil {
+		return ""
+	}
+	return v.overrides[v.key]
+}
+
+func (v *overrideValue) Set(value string) error {
+	v.overrides[v.key] = value
+	return nil
+}
+
+// IsBoolFlag は --log-enabled のように値を省略できるようにする
+func (v *overrideValue) IsBoolFlag() bool {
+	return v.isBool
+}
@@ -0,0 +1,90 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ソケットへの接続を待つ時間
+const dialTimeout = 2 * time.Second
+
+// Call はpathのソケットにリクエストを送って応答を返す
+// 公開しているプロセスが無い場合は ErrNotRunning を返す
+func Call(path string, request Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return Response{}, err
+	}
+
+	var response Response
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return Response{}, err
+	}
+	if err := json.Unmarshal(line, &response); err != nil {
+		return Response{}, err
+	}
+	if response.Status != STATUS_OK {
+		return response, errors.New(response.Message)
+	}
+	return response, nil
+}
+
+// Running はpathのソケットで公開しているプロセスが応答するかを返す
+func Running(path string) bool {
+	_, err := Status(path)
+	return err == nil
+}
+
+// Status は公開状態を返す
+func Status(path string) (Snapshot, error) {
+	return snapshot(path, COMMAND_STATUS)
+}
+
+// Stats は公開状態と通信量・拒否数・接続一覧を返す
+func Stats(path string) (Snapshot, error) {
+	return snapshot(path, COMMAND_STATS)
+}
+
+func snapshot(path, command string) (Snapshot, error) {
+	response, err := Call(path, Request{Command: command})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if response.Snapshot == nil {
+		return Snapshot{}, errors.New("応答に状態が含まれていません")
+	}
+	return *response.Snapshot, nil
+}
+
+// Stop は公開を停止させる。停止の完了は待たない
+func Stop(path string) error {
+	_, err := Call(path, Request{Command: COMMAND_STOP})
+	return err
+}
+
+// Reload は設定とトークンを読み込み直させる
+func Reload(path string) error {
+	_, err := Call(path, Request{Command: COMMAND_RELOAD})
+	return err
+}
+
+// Kick は接続を切断させる
+func Kick(path, connID string) error {
+	_, err := Call(path, Request{Command: COMMAND_KICK, ConnID: connID})
+	return err
+}
@@ -0,0 +1,94 @@
+// Package control は公開中のトンネルを別のプロセスから操作するための、Unixドメインソケットの制御API
+//
+// up やTUIでポートを公開しているプロセスがソケットで待ち受け、
+// 後から起動したCLIやTUIはそこへ接続して状態を読んだり停止・再読み込みを頼んだりする。
+// 1回の接続で1行のJSONのリクエストを送り、1行のJSONの応答を受け取る
+package control
+
+import (
+	"QuickPort/internal/core"
+	"QuickPort/internal/statedir"
+	"errors"
+	"time"
+)
+
+// ソケットの名前
+const SocketName = "quickport.sock"
+
+// DefaultSocketPath は状態ディレクトリにある既定のソケットのパスを返す
+// どのフォルダから起動しても、同じユーザーなら同じソケットに接続する
+func DefaultSocketPath() string {
+	return statedir.Path(SocketName)
+}
+
+// ProfileSocketPath は既定以外のプロファイルで公開するときのソケットのパスを返す
+func ProfileSocketPath(profile string) string {
+	return statedir.Path("quickport-" + profile + ".sock")
+}
+
+// コマンド
+const (
+	COMMAND_STATUS = "status" // 公開状態を返す
+	COMMAND_STATS  = "stats"  // 公開状態に加えて通信量・拒否数・接続一覧を返す
+	COMMAND_STOP   = "stop"   // 公開を停止する
+	COMMAND_RELOAD = "reload" // 設定とトークンを読み込み直して接続し直す
+	COMMAND_KICK   = "kick"   // 指定した接続を切断する
+)
+
+// 応答の結果
+const (
+	STATUS_OK    = "OK"
+	STATUS_ERROR = "ERROR"
+)
+
+// ErrNotRunning はソケットに接続できない（公開しているプロセスが無い）ことを表す
+var ErrNotRunning = errors.New("公開していません")
+
+// Request は制御APIへのリクエスト
+type Request struct {
+	Command string `json:"command"`
+	ConnID  string `json:"conn_id,omitempty"` // kick で切断する接続
+}
+
+// Response は制御APIの応答
+type Response struct {
+	Status   string    `json:"status"`
+	Message  string    `json:"message,omitempty"`
+	Snapshot *Snapshot `json:"snapshot,omitempty"` // status / stats の結果
+}
+
+// Snapshot は公開中のトンネルの状態
+type Snapshot struct {
+	PID        int                 `json:"pid"`
+	Started    time.Time           `json:"started"`
+	Status     core.Status         `json:"status"`
+	RTT        time.Duration       `json:"rtt"`
+	Traffic    core.TrafficStats   `json:"traffic"`    // stats のみ
+	Rejections core.RejectionStats `json:"rejections"` // stats のみ
+	// stats のみ
+	Connections []core.ConnectionInfo `json:"connections,omitempty"`
+}
+
+// NewSnapshot はclientの現在の状態を集める。fullがfalseの場合は接続状態だけを集める
+func NewSnapshot(client *core.FRPClient, pid int, started time.Time, full bool) Snapshot {
+	snapshot := Snapshot{
+		PID:     pid,
+		Started: started,
+		Status:  client.Status(),
+		RTT:     client.RTT(),
+	}
+	if full {
+		snapshot.Traffic = client.Traffic()
+		snapshot.Rejections = client.Rejections()
+		snapshot.Connections = client.Connections()
+	}
+	return snapshot
+}
+
+// Handler は制御APIのコマンドを実行する
+type Handler interface {
+	Snapshot(full bool) Snapshot
+	Stop()
+	Reload() error
+	Kick(connID string) error
+}
@@ -0,0 +1,241 @@
+package control
+
+import (
+	"QuickPort/internal/core"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// テストで応答を待つ時間の上限
+const testTimeout = 10 * time.Second
+
+// fakeHandler は受け取ったコマンドを記録する Handler
+type fakeHandler struct {
+	mutex     sync.Mutex
+	reloadErr error
+	reloads   int
+	kicked    []string
+	stopped   chan struct{}
+}
+
+func newFakeHandler() *fakeHandler {
+	return &fakeHandler{stopped: make(chan struct{}, 1)}
+}
+
+func (h *fakeHandler) Snapshot(full bool) Snapshot {
+	snapshot := Snapshot{
+		PID:     42,
+		Started: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Status:  core.Status{Running: true, PublicAddr: "quickport.natyosu.com:40000"},
+		RTT:     25 * time.Millisecond,
+	}
+	if full {
+		snapshot.Traffic = core.TrafficStats{BytesIn: 100, BytesOut: 200}
+		snapshot.Connections = []core.ConnectionInfo{{ID: "c1", ProxyName: "tcp", RemoteAddr: "203.0.113.5:50000"}}
+	}
+	return snapshot
+}
+
+func (h *fakeHandler) Stop() {
+	h.stopped <- struct{}{}
+}
+
+func (h *fakeHandler) Reload() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.reloads++
+	return h.reloadErr
+}
+
+func (h *fakeHandler) Kick(connID string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if connID != "c1" {
+		return errors.New("接続が見つかりません: " + connID)
+	}
+	h.kicked = append(h.kicked, connID)
+	return nil
+}
+
+// socketPath はテスト用のソケットのパスを返す
+// Unixドメインソケットのパスは短くなければならないので、t.TempDir は使わない
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "qp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, SocketName)
+}
+
+// serve はpathで制御APIを待ち受け、テストの終わりに閉じる
+func serve(t *testing.T, path string, handler Handler) net.Listener {
+	t.Helper()
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go Serve(listener, handler)
+	return listener
+}
+
+// status と stats はそれぞれの範囲の状態を返すこと
+func TestStatusAndStats(t *testing.T) {
+	path := socketPath(t)
+	serve(t, path, newFakeHandler())
+
+	status, err := Status(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PID != 42 || status.Status.PublicAddr != "quickport.natyosu.com:40000" || status.RTT != 25*time.Millisecond {
+		t.Fatalf("status = %+v", status)
+	}
+	if len(status.Connections) != 0 || status.Traffic.BytesIn != 0 {
+		t.Fatalf("status included stats: %+v", status)
+	}
+	if !Running(path) {
+		t.Fatal("Running = false while serving")
+	}
+
+	stats, err := Stats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Traffic.BytesOut != 200 || len(stats.Connections) != 1 || stats.Connections[0].ID != "c1" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if !stats.Started.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("started = %v", stats.Started)
+	}
+}
+
+// stop・reload・kick をハンドラで実行し、失敗した場合はそのメッセージを返すこと
+func TestCommands(t *testing.T) {
+	path := socketPath(t)
+	handler := newFakeHandler()
+	serve(t, path, handler)
+
+	if err := Stop(path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handler.stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("stop was not delivered to the handler")
+	}
+
+	if err := Reload(path); err != nil {
+		t.Fatal(err)
+	}
+	handler.mutex.Lock()
+	handler.reloadErr = errors.New("トークンがありません")
+	handler.mutex.Unlock()
+	if err := Reload(path); err == nil || err.Error() != "トークンがありません" {
+		t.Fatalf("Reload err = %v, want the handler error", err)
+	}
+
+	if err := Kick(path, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Kick(path, "c2"); err == nil {
+		t.Fatal("Kick of an unknown connection succeeded")
+	}
+
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	if handler.reloads != 2 {
+		t.Fatalf("reloads = %d, want 2", handler.reloads)
+	}
+	if len(handler.kicked) != 1 || handler.kicked[0] != "c1" {
+		t.Fatalf("kicked = %v, want [c1]", handler.kicked)
+	}
+}
+
+// 不明なコマンドはエラーの応答を返すこと
+func TestUnknownCommand(t *testing.T) {
+	path := socketPath(t)
+	serve(t, path, newFakeHandler())
+
+	response, err := Call(path, Request{Command: "restart"})
+	if err == nil {
+		t.Fatal("unknown command succeeded")
+	}
+	if response.Status != STATUS_ERROR {
+		t.Fatalf("response = %+v", response)
+	}
+}
+
+// 他のユーザーが操作できないように、ソケットは本人だけが読み書きできること
+func TestSocketPermission(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not used on Windows")
+	}
+	path := socketPath(t)
+	serve(t, path, newFakeHandler())
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("socket perm = %o, want 600", perm)
+	}
+}
+
+// 前回異常終了したときのソケットは作り直し、応答するソケットは奪わないこと
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+
+	// ファイルを残したまま閉じて、異常終了した状態にする
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("stale socket was removed: %v", err)
+	}
+	if _, err := Status(path); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Status on a stale socket err = %v, want ErrNotRunning", err)
+	}
+
+	serve(t, path, newFakeHandler())
+	if !Running(path) {
+		t.Fatal("replaced socket does not answer")
+	}
+
+	if listener, err := Listen(path); err == nil {
+		listener.Close()
+		t.Fatal("Listen took over a socket that is still answering")
+	}
+}
+
+// 公開しているプロセスが無ければ ErrNotRunning を返すこと
+func TestNotRunning(t *testing.T) {
+	path := socketPath(t)
+
+	if Running(path) {
+		t.Fatal("Running = true without a process")
+	}
+	for name, call := range map[string]func() error{
+		"status": func() error { _, err := Status(path); return err },
+		"stats":  func() error { _, err := Stats(path); return err },
+		"stop":   func() error { return Stop(path) },
+		"reload": func() error { return Reload(path) },
+		"kick":   func() error { return Kick(path, "c1") },
+	} {
+		if err := call(); !errors.Is(err, ErrNotRunning) {
+			t.Errorf("%s err = %v, want ErrNotRunning", name, err)
+		}
+	}
+}
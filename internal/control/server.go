@@ -0,0 +1,118 @@
+package control
+
+import (
+	"QuickPort/internal/statedir"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 1回のリクエストを読み書きする時間の上限
+const requestTimeout = 10 * time.Second
+
+// Listen はpathで制御APIを待ち受ける
+// 前回異常終了したときのソケットが残っている場合は、応答が無いことを確かめてから作り直す
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), statedir.DirPerm); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err == nil {
+		restrictPermission(path)
+		return listener, nil
+	}
+	if _, statErr := os.Stat(path); statErr != nil {
+		return nil, err
+	}
+
+	if Running(path) {
+		return nil, errors.New("すでに別のプロセスで公開中です")
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	listener, err = net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	restrictPermission(path)
+	return listener, nil
+}
+
+// restrictPermission は他のユーザーがトンネルを操作できないようにする
+func restrictPermission(path string) {
+	if err := os.Chmod(path, 0600); err != nil {
+		log.Printf("Failed to chmod %s: %v", path, err)
+	}
+}
+
+// Serve はlistenerが閉じられるまでリクエストを受け付け、handlerで実行する
+func Serve(listener net.Listener, handler Handler) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Control accept error: %v", err)
+			}
+			return
+		}
+		go serveConn(conn, handler)
+	}
+}
+
+func serveConn(conn net.Conn, handler Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var request Request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		log.Printf("Control read error: %v", err)
+		return
+	}
+	var response Response
+	if err := json.Unmarshal(line, &request); err != nil {
+		response = Response{Status: STATUS_ERROR, Message: "リクエストを読み取れません: " + err.Error()}
+	} else {
+		response = handle(request, handler)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Control encode error: %v", err)
+		return
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		log.Printf("Control write error: %v", err)
+	}
+}
+
+func handle(request Request, handler Handler) Response {
+	switch request.Command {
+	case COMMAND_STATUS, COMMAND_STATS:
+		snapshot := handler.Snapshot(request.Command == COMMAND_STATS)
+		return Response{Status: STATUS_OK, Snapshot: &snapshot}
+	case COMMAND_STOP:
+		// 応答を返してから止める
+		go handler.Stop()
+		return Response{Status: STATUS_OK, Message: "停止します"}
+	case COMMAND_RELOAD:
+		if err := handler.Reload(); err != nil {
+			return Response{Status: STATUS_ERROR, Message: err.Error()}
+		}
+		return Response{Status: STATUS_OK, Message: "再読み込みしました"}
+	case COMMAND_KICK:
+		if err := handler.Kick(request.ConnID); err != nil {
+			return Response{Status: STATUS_ERROR, Message: err.Error()}
+		}
+		return Response{Status: STATUS_OK, Message: "切断しました"}
+	default:
+		return Response{Status: STATUS_ERROR, Message: fmt.Sprintf("不明なコマンドです: %s", request.Command)}
+	}
+}
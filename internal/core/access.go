@@ -0,0 +1,95 @@
+package core
+
+import (
+	"QuickPort/internal/acl"
+	"QuickPort/internal/minecraft"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+// ログイン開始を待つ時間
+const playerGateTimeout = 10 * time.Second
+
+// 拒否したプレイヤーに表示する切断理由
+const playerDeniedMessage = "このサーバーへの参加は許可されていません"
+
+// accessRules は現在のアクセス制限を返す（設定が無ければ条件なし）
+func (c *FRPClient) accessRules() *acl.Rules {
+	if c.options.AccessList == nil {
+		return &acl.Rules{}
+	}
+	return c.options.AccessList.Rules()
+}
+
+// rejectConnection はローカルサービスへ接続せずにストリームを閉じる
+func (c *FRPClient) rejectConnection(msg *Message, reason string) {
+	log.Printf("Rejected connection %s from %s: %s", msg.ConnID, remoteHost(msg.RemoteAddr), reason)
+	c.sendCloseMessageWithReason(msg.ConnID, reason)
+}
+
+// gateByPlayer はログイン開始からプレイヤー名を確かめてからローカルサービスへ接続する仮想的な接続を返す
+// 戻り値はローカルサービスへの接続と同じようにストリームに渡せる
+func (c *FRPClient) gateByPlayer(proxyConfig ProxyConfig, msg *Message, rules *acl.Rules) net.Conn {
+	relaySide, gate := net.Pipe()
+	go c.runPlayerGate(gate, proxyConfig, msg.ConnID, msg.RemoteAddr, rules)
+	return relaySide
+}
+
+func (c *FRPClient) runPlayerGate(gate net.Conn, proxyConfig ProxyConfig, connID, remoteAddr string, rules *acl.Rules) {
+	defer gate.Close()
+
+	// ハンドシェイクとログイン開始が揃うまで読む（Minecraftの通信でなければそこで止める）
+	inspector := minecraft.NewInspector()
+	var head []byte
+	buffer := make([]byte, streamReadSize)
+	gate.SetReadDeadline(time.Now().Add(playerGateTimeout))
+	for !inspector.Done() {
+		n, err := gate.Read(buffer)
+		if err != nil {
+			return
+		}
+		head = append(head, buffer[:n]...)
+		inspector.Feed(buffer[:n])
+	}
+	gate.SetReadDeadline(time.Time{})
+
+	info := inspector.Info()
+	switch {
+	case info.PlayerName != "":
+		if reason, ok := rules.CheckPlayer(info.PlayerName); !ok {
+			log.Printf("Rejected player %s from %s (%s): %s", info.PlayerName, remoteHost(remoteAddr), connID, reason)
+			c.limiter.denied()
+			minecraft.WriteLoginDisconnect(gate, playerDeniedMessage)
+			return
+		}
+	case inspector.HasHandshake() && info.NextState == minecraft.NextStateStatus:
+		// サーバーリストのpingにはプレイヤー名が無いので、許可リストがあっても通す
+	case len(rules.AllowPlayers) > 0:
+		// プレイヤー名が分からない接続は許可リストと照合できないので通さない
+		log.Printf("Rejected connection %s from %s: not a Minecraft login", connID, remoteHost(remoteAddr))
+		c.limiter.denied()
+		if inspector.HasHandshake() && info.IsLogin() {
+			minecraft.WriteLoginDisconnect(gate, playerDeniedMessage)
+		}
+		return
+	}
+
+	local, _, err := c.openLocal(proxyConfig, remoteAddr)
+	if err != nil {
+		log.Printf("Failed to connect to local service for %s: %v", connID, err)
+		return
+	}
+	defer local.Close()
+
+	if _, err := local.Write(head); err != nil {
+		return
+	}
+
+	go func() {
+		io.Copy(local, gate)
+		local.Close()
+	}()
+	io.Copy(gate, local)
+}
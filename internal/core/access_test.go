@@ -0,0 +1,144 @@
+package core
+
+import (
+	"QuickPort/internal/acl"
+	"QuickPort/internal/minecraft"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handshakePacket はハンドシェイクパケットを作る
+func handshakePacket(nextState int32) []byte {
+	payload := minecraft.AppendVarInt(nil, 767)
+	payload = minecraft.AppendString(payload, "quickport.natyosu.com")
+	payload = binary.BigEndian.AppendUint16(payload, 25565)
+	payload = minecraft.AppendVarInt(payload, nextState)
+
+	var buf bytes.Buffer
+	minecraft.WritePacket(&buf, minecraft.PacketHandshake, payload)
+	return buf.Bytes()
+}
+
+// loginPacket はハンドシェイクとログイン開始を続けて作る
+func loginPacket(name string) []byte {
+	var buf bytes.Buffer
+	buf.Write(handshakePacket(minecraft.NextStateLogin))
+	minecraft.WritePacket(&buf, minecraft.PacketLoginStart, minecraft.AppendString(nil, name))
+	return buf.Bytes()
+}
+
+// startGatedClient は allow player Steve を設定したクライアントを起動する
+// tcp はMinecraftサーバ、dynmap はそれ以外のプロキシで、どちらも同じローカルサービスへ転送する
+func startGatedClient(t *testing.T) (*relayConn, chan []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), acl.FileName)
+	if err := os.WriteFile(path, []byte("allow player Steve\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accessList, err := acl.NewList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ローカルサービスに届いた先頭のデータ
+	received := make(chan []byte, 8)
+	localPort := listenLocal(t, func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, 4096)
+		n, _ := conn.Read(buffer)
+		received <- buffer[:n]
+	})
+
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_TCP,
+		Proxies: []ProxyConfig{
+			{Name: PROXY_TYPE_TCP, Type: PROXY_TYPE_TCP, LocalIP: "127.0.0.1", LocalPort: localPort, RemotePort: 40000},
+			{Name: "dynmap", Type: PROXY_TYPE_TCP, LocalIP: "127.0.0.1", LocalPort: localPort, RemotePort: 40001},
+		},
+	})
+	startClient(t, relay.Addr(), Options{AccessList: accessList})
+	return relay.accept(), received
+}
+
+// openStream はストリームを開いて先頭のデータを送る
+func openStream(rc *relayConn, proxyName, connID string, data []byte) {
+	rc.send(Message{Type: MSG_TYPE_NEW_CONN, ProxyName: proxyName, ConnID: connID, RemoteAddr: "203.0.113.5:50000"})
+	// ストリームが登録されるまで少し待つ
+	time.Sleep(50 * time.Millisecond)
+	rc.send(Message{Type: MSG_TYPE_DATA, ConnID: connID, Data: data})
+}
+
+// waitClose はストリームがリレー側へ閉じられるまで待つ
+func waitClose(t *testing.T, rc *relayConn, connID string) {
+	t.Helper()
+	for {
+		msg := rc.next()
+		if msg.Type == MSG_TYPE_CLOSE && msg.ConnID == connID {
+			return
+		}
+	}
+}
+
+// waitLocal はローカルサービスにデータが届くまで待つ
+func waitLocal(t *testing.T, received chan []byte, want []byte) {
+	t.Helper()
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, want) {
+			t.Fatalf("local service received %q, want %q", data, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("connection was not forwarded to the local service")
+	}
+}
+
+// assertNotForwarded はローカルサービスへ接続していないことを確かめる
+func assertNotForwarded(t *testing.T, received chan []byte) {
+	t.Helper()
+	select {
+	case data := <-received:
+		t.Fatalf("rejected connection reached the local service: %q", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// 許可リストがある場合、Minecraftのログインでない接続はローカルサービスへ届かないこと
+func TestPlayerAllowlistRejectsNonLogin(t *testing.T) {
+	rc, received := startGatedClient(t)
+
+	openStream(rc, PROXY_TYPE_TCP, "junk", []byte(strings.Repeat("G", 200)))
+	waitClose(t, rc, "junk")
+	assertNotForwarded(t, received)
+
+	openStream(rc, PROXY_TYPE_TCP, "alex", loginPacket("Alex"))
+	waitClose(t, rc, "alex")
+	assertNotForwarded(t, received)
+}
+
+// 許可したプレイヤーのログインとサーバーリストのpingは通すこと
+func TestPlayerAllowlistAllowsLoginAndStatus(t *testing.T) {
+	rc, received := startGatedClient(t)
+
+	login := loginPacket("Steve")
+	openStream(rc, PROXY_TYPE_TCP, "steve", login)
+	waitLocal(t, received, login)
+
+	status := handshakePacket(minecraft.NextStateStatus)
+	openStream(rc, PROXY_TYPE_TCP, "ping", status)
+	waitLocal(t, received, status)
+}
+
+// Minecraft以外のプロキシはプレイヤー名の確認を待たずに転送すること
+func TestPlayerAllowlistSkipsOtherProxies(t *testing.T) {
+	rc, received := startGatedClient(t)
+
+	request := []byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	openStream(rc, "dynmap", "web", request)
+	waitLocal(t, received, request)
+}
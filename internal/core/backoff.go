@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// 再接続の待ち時間の設定
+type BackoffConfig struct {
+	Initial     time.Duration // 2回目の再接続までの待ち時間（1回目は即座に再接続する）
+	Max         time.Duration // 待ち時間の上限
+	Multiplier  float64       // 失敗するごとに待ち時間に掛ける倍率
+	Jitter      float64       // 待ち時間をランダムにずらす割合（0〜1）
+	MaxAttempts int           // 再接続を諦めるまでの試行回数（0なら無制限）
+}
+
+func DefaultBackoffConfig() BackoffConfig {
+	return BackoffConfig{
+		Initial:     time.Second,
+		Max:         60 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxAttempts: 0,
+	}
+}
+
+// withDefaults は未設定の項目を既定値で埋める
+func (b BackoffConfig) withDefaults() BackoffConfig {
+	def := DefaultBackoffConfig()
+	if b.Initial <= 0 {
+		b.Initial = def.Initial
+	}
+	if b.Max <= 0 {
+		b.Max = def.Max
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = def.Multiplier
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		b.Jitter = def.Jitter
+	}
+	return b
+}
+
+// delay はattempt回目（1始まり）の再接続までの待ち時間を返す
+func (b BackoffConfig) delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		// 一時的な瞬断ならすぐに戻れるように、最初の再接続は待たない
+		return 0
+	}
+
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-2))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		// ±Jitterの範囲でずらし、大勢のクライアントが同時に再接続しないようにする
+		d *= 1 + b.Jitter*(2*rand.Float64()-1)
+	}
+	return min(time.Duration(d), b.Max)
+}
+
+// 接続状態の種類
+type ConnStateKind int
+
+const (
+	StateIdle       ConnStateKind = iota // 未起動・停止済み
+	StateConnecting                      // 接続・ログイン中
+	StateConnected                       // 接続済み
+	StateBackoff                         // 再接続の待機中
+	StateGaveUp                          // 再接続を諦めた
+)
+
+func (k ConnStateKind) String() string {
+	switch k {
+	case StateIdle:
+		return "Idle"
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateBackoff:
+		return "Backoff"
+	case StateGaveUp:
+		return "GaveUp"
+	}
+	return "Unknown"
+}
+
+// 接続状態
+type ConnState struct {
+	Kind        ConnStateKind
+	Attempt     int       // 再接続の試行回数（Connecting/Backoff/GaveUp）
+	NextAttempt time.Time // 次の再接続予定時刻（Backoff）
+	LastError   string    // 直前の切断・接続失敗の理由
+}
+
+func (s ConnState) String() string {
+	switch s.Kind {
+	case StateBackoff:
+		return fmt.Sprintf("Backoff(%d, %s)", s.Attempt, s.NextAttempt.Format(time.TimeOnly))
+	case StateConnecting, StateGaveUp:
+		if s.Attempt > 0 {
+			return fmt.Sprintf("%s(%d)", s.Kind, s.Attempt)
+		}
+	}
+	return s.Kind.String()
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// 制御コネクション上のエンコード方式
+const (
+	CODEC_JSON   = "json"
+	CODEC_BINARY = "binary/1"
+)
+
+// バイナリフレームの種類
+const (
+	FRAME_TYPE_DATA    byte = 0x01 // ペイロードは生データ
+	FRAME_TYPE_CLOSE   byte = 0x02 // ペイロードは切断理由（任意）
+	FRAME_TYPE_CONTROL byte = 0x03 // ペイロードはJSONエンコードされたMessage
+
+	FRAME_TYPE_WINDOW_UPDATE byte = 0x04 // ペイロードは払い戻すバイト数(uint32)
+	FRAME_TYPE_PING          byte = 0x05 // ペイロードはpingの送信時刻(int64)
+	FRAME_TYPE_PONG          byte = 0x06 // ペイロードは受け取ったpingの送信時刻(int64)
+	FRAME_TYPE_UDP           byte = 0x07 // stream IDは送信元アドレス、ペイロードはプロキシ名長(1) + プロキシ名 + データグラム
+)
+
+// バイナリフレームのヘッダ長
+// type(1) + flags(1) + stream ID長(2) + ペイロード長(4)
+// flags は将来の拡張のための予約で、常に0を書き込み、0以外のフレームは受け付けない
+const frameHeaderSize = 8
+
+// 1フレームあたりのペイロード上限（不正なフレームでメモリを食い潰さないため）
+const maxFramePayload = 16 << 20
+
+// codec はMessageを制御コネクションに読み書きする
+type codec interface {
+	Name() string
+	WriteMessage(msg *Message) error
+	ReadMessage(msg *Message) error
+}
+
+// JSONによる従来の方式（古いリレーサーバ向けのフォールバック）
+type jsonCodec struct {
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+func newJSONCodec(r io.Reader, w io.Writer) *jsonCodec {
+	return &jsonCodec{
+		encoder: json.NewEncoder(w),
+		decoder: json.NewDecoder(r),
+	}
+}
+
+func (j *jsonCodec) Name() string {
+	return CODEC_JSON
+}
+
+func (j *jsonCodec) WriteMessage(msg *Message) error {
+	return j.encoder.Encode(msg)
+}
+
+func (j *jsonCodec) ReadMessage(msg *Message) error {
+	return j.decoder.Decode(msg)
+}
+
+// buffered はデコーダが読み込み済みでまだ消費していないデータを返す
+// コーデック切り替え時に取りこぼさないために使用する
+func (j *jsonCodec) buffered() io.Reader {
+	return j.decoder.Buffered()
+}
+
+// 長さ付きバイナリフレームによる方式
+//
+//	+--------+---------+--------------+----------------+-----------+---------+
+//	| type 1 | flags 1 | stream ID長 2 | ペイロード長 4 | stream ID | payload |
+//	+--------+---------+--------------+----------------+-----------+---------+
+//
+// 数値はすべてビッグエンディアン。flags は予約済み（常に0）
+type binaryCodec struct {
+	reader *bufio.Reader
+	writer io.Writer
+	// JSONから切り替えた直後は、ログイン応答の後ろに付く改行が残っている
+	skipJSONWhitespace bool
+}
+
+func newBinaryCodec(r io.Reader, w io.Writer) *binaryCodec {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &binaryCodec{
+		reader: br,
+		writer: w,
+	}
+}
+
+// JSONコーデックからの切り替え用
+// フレーム種別に空白文字と同じ値は使っていないので、先頭の空白は読み飛ばしてよい
+func newBinaryCodecAfterJSON(r io.Reader, w io.Writer) *binaryCodec {
+	b := newBinaryCodec(r, w)
+	b.skipJSONWhitespace = true
+	return b
+}
+
+func (b *binaryCodec) Name() string {
+	return CODEC_BINARY
+}
+
+func (b *binaryCodec) WriteMessage(msg *Message) error {
+	var frameType byte
+	var payload []byte
+
+	switch msg.Type {
+	case MSG_TYPE_DATA:
+		frameType = FRAME_TYPE_DATA
+		payload = msg.Data
+	case MSG_TYPE_CLOSE:
+		frameType = FRAME_TYPE_CLOSE
+		payload = []byte(msg.ErrorMsg)
+	case MSG_TYPE_WINDOW_UPDATE:
+		frameType = FRAME_TYPE_WINDOW_UPDATE
+		payload = binary.BigEndian.AppendUint32(nil, uint32(msg.Window))
+	case MSG_TYPE_UDP:
+		if len(msg.ProxyName) > 0xFF {
+			return fmt.Errorf("proxy name too long: %d bytes", len(msg.ProxyName))
+		}
+		frameType = FRAME_TYPE_UDP
+		payload = make([]byte, 0, 1+len(msg.ProxyName)+len(msg.Data))
+		payload = append(payload, byte(len(msg.ProxyName)))
+		payload = append(payload, msg.ProxyName...)
+		payload = append(payload, msg.Data...)
+		return writeFrame(b.writer, frameType, msg.RemoteAddr, payload)
+	case MSG_TYPE_PING, MSG_TYPE_PONG:
+		frameType = FRAME_TYPE_PING
+		if msg.Type == MSG_TYPE_PONG {
+			frameType = FRAME_TYPE_PONG
+		}
+		payload = binary.BigEndian.AppendUint64(nil, uint64(msg.Timestamp))
+	default:
+		// データ以外のメッセージは頻度が低いのでJSONのまま載せる
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		frameType = FRAME_TYPE_CONTROL
+		payload = body
+	}
+
+	return writeFrame(b.writer, frameType, msg.ConnID, payload)
+}
+
+func (b *binaryCodec) ReadMessage(msg *Message) error {
+	if b.skipJSONWhitespace {
+		if err := b.discardWhitespace(); err != nil {
+			return err
+		}
+		b.skipJSONWhitespace = false
+	}
+
+	frameType, streamID, payload, err := readFrame(b.reader)
+	if err != nil {
+		return err
+	}
+
+	*msg = Message{}
+	switch frameType {
+	case FRAME_TYPE_DATA:
+		msg.Type = MSG_TYPE_DATA
+		msg.ConnID = streamID
+		msg.Data = payload
+	case FRAME_TYPE_CLOSE:
+		msg.Type = MSG_TYPE_CLOSE
+		msg.ConnID = streamID
+		msg.ErrorMsg = string(payload)
+	case FRAME_TYPE_WINDOW_UPDATE:
+		if len(payload) != 4 {
+			return fmt.Errorf("invalid window update frame: %d bytes", len(payload))
+		}
+		msg.Type = MSG_TYPE_WINDOW_UPDATE
+		msg.ConnID = streamID
+		msg.Window = int(binary.BigEndian.Uint32(payload))
+	case FRAME_TYPE_UDP:
+		if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+			return fmt.Errorf("invalid udp frame: %d bytes", len(payload))
+		}
+		nameLen := int(payload[0])
+		msg.Type = MSG_TYPE_UDP
+		msg.RemoteAddr = streamID
+		msg.ProxyName = string(payload[1 : 1+nameLen])
+		msg.Data = payload[1+nameLen:]
+	case FRAME_TYPE_PING, FRAME_TYPE_PONG:
+		if len(payload) != 8 {
+			return fmt.Errorf("invalid ping frame: %d bytes", len(payload))
+		}
+		msg.Type = MSG_TYPE_PING
+		if frameType == FRAME_TYPE_PONG {
+			msg.Type = MSG_TYPE_PONG
+		}
+		msg.Timestamp = int64(binary.BigEndian.Uint64(payload))
+	case FRAME_TYPE_CONTROL:
+		if err := json.Unmarshal(payload, msg); err != nil {
+			return fmt.Errorf("invalid control frame: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown frame type: 0x%02x", frameType)
+	}
+	return nil
+}
+
+func (b *binaryCodec) discardWhitespace() error {
+	for {
+		c, err := b.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b.reader.UnreadByte()
+	}
+}
+
+// writeFrame はヘッダとペイロードを1回のWriteで書き込む
+func writeFrame(w io.Writer, frameType byte, streamID string, payload []byte) error {
+	if len(streamID) > 0xFFFF {
+		return fmt.Errorf("stream id too long: %d bytes", len(streamID))
+	}
+	if len(payload) > maxFramePayload {
+		return fmt.Errorf("frame payload too large: %d bytes", len(payload))
+	}
+
+	frame := make([]byte, frameHeaderSize+len(streamID)+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint16(frame[2:4], uint16(len(streamID)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	copy(frame[frameHeaderSize:], streamID)
+	copy(frame[frameHeaderSize+len(streamID):], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) (frameType byte, streamID string, payload []byte, err error) {
+	var header [frameHeaderSize]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+
+	frameType = header[0]
+	if header[1] != 0 {
+		err = fmt.Errorf("unsupported frame flags: 0x%02x", header[1])
+		return
+	}
+	idLen := int(binary.BigEndian.Uint16(header[2:4]))
+	payloadLen := int(binary.BigEndian.Uint32(header[4:8]))
+	if payloadLen > maxFramePayload {
+		err = fmt.Errorf("frame payload too large: %d bytes", payloadLen)
+		return
+	}
+
+	body := make([]byte, idLen+payloadLen)
+	if _, err = io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+
+	streamID = string(body[:idLen])
+	payload = body[idLen:]
+	return
+}
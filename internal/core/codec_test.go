@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodeFrames はmessagesをバイナリフレームにして返す
+func encodeFrames(t *testing.T, messages ...Message) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	codec := newBinaryCodec(nil, &buffer)
+	for _, msg := range messages {
+		if err := codec.WriteMessage(&msg); err != nil {
+			t.Fatalf("write %+v: %v", msg, err)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// すべてのフレームの種類が書いたとおりに読めること
+func TestBinaryCodecRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Type: MSG_TYPE_DATA, ConnID: "c1", Data: []byte("hello")},
+		{Type: MSG_TYPE_CLOSE, ConnID: "c1", ErrorMsg: "connection refused"},
+		{Type: MSG_TYPE_CLOSE, ConnID: "c2"},
+		{Type: MSG_TYPE_WINDOW_UPDATE, ConnID: "c1", Window: streamWindowSize},
+		{Type: MSG_TYPE_PING, Timestamp: 1700000000123456789},
+		{Type: MSG_TYPE_PONG, Timestamp: 1700000000123456789},
+		{Type: MSG_TYPE_UDP, ProxyName: "voice", RemoteAddr: "203.0.113.5:50000", Data: []byte{0x00, 0xff}},
+		{Type: MSG_TYPE_NEW_CONN, ConnID: "c3", ProxyName: "tcp", RemoteAddr: "203.0.113.5:50000"},
+	}
+	codec := newBinaryCodec(bytes.NewReader(encodeFrames(t, messages...)), nil)
+
+	for _, want := range messages {
+		var got Message
+		if err := codec.ReadMessage(&got); err != nil {
+			t.Fatalf("read %s: %v", want.Type, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("read %+v, want %+v", got, want)
+		}
+	}
+	var msg Message
+	if err := codec.ReadMessage(&msg); err != io.EOF {
+		t.Fatalf("read after the last frame err = %v, want io.EOF", err)
+	}
+}
+
+// stream IDは2バイトで表せる長さまで書けること
+func TestBinaryCodecMaxStreamID(t *testing.T) {
+	want := Message{Type: MSG_TYPE_DATA, ConnID: strings.Repeat("a", 0xFFFF), Data: []byte("x")}
+	codec := newBinaryCodec(bytes.NewReader(encodeFrames(t, want)), nil)
+	var got Message
+	if err := codec.ReadMessage(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stream id of %d bytes did not round trip", len(got.ConnID))
+	}
+
+	tooLong := Message{Type: MSG_TYPE_DATA, ConnID: strings.Repeat("a", 0x10000), Data: []byte("x")}
+	if err := newBinaryCodec(nil, io.Discard).WriteMessage(&tooLong); err == nil {
+		t.Fatal("stream id longer than 0xFFFF bytes was written")
+	}
+	tooLarge := Message{Type: MSG_TYPE_DATA, ConnID: "c1", Data: make([]byte, maxFramePayload+1)}
+	if err := newBinaryCodec(nil, io.Discard).WriteMessage(&tooLarge); err == nil {
+		t.Fatal("payload larger than maxFramePayload was written")
+	}
+}
+
+// ヘッダやペイロードの途中で切れたフレームは io.ErrUnexpectedEOF になること
+func TestBinaryCodecTruncated(t *testing.T) {
+	frame := encodeFrames(t, Message{Type: MSG_TYPE_DATA, ConnID: "c1", Data: []byte("hello")})
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"header", frameHeaderSize - 1},
+		{"stream id", frameHeaderSize + 1},
+		{"payload", len(frame) - 1},
+	}
+	for _, tt := range tests {
+		codec := newBinaryCodec(bytes.NewReader(frame[:tt.size]), nil)
+		var msg Message
+		if err := codec.ReadMessage(&msg); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: err = %v, want io.ErrUnexpectedEOF", tt.name, err)
+		}
+	}
+}
+
+// 不正なヘッダ・ペイロードのフレームはエラーにすること
+func TestBinaryCodecInvalidFrame(t *testing.T) {
+	valid := encodeFrames(t, Message{Type: MSG_TYPE_DATA, ConnID: "c1", Data: []byte("hello")})
+	withHeader := func(index int, value byte) []byte {
+		frame := bytes.Clone(valid)
+		frame[index] = value
+		return frame
+	}
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"flags", withHeader(1, 0x01)},
+		{"unknown type", withHeader(0, 0x7f)},
+		{"huge payload", concat([]byte{FRAME_TYPE_DATA, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})},
+		{"short window update", concat([]byte{FRAME_TYPE_WINDOW_UPDATE, 0, 0, 0, 0, 0, 0, 2}, []byte{0, 1})},
+		{"short ping", concat([]byte{FRAME_TYPE_PING, 0, 0, 0, 0, 0, 0, 1}, []byte{1})},
+		{"udp name", concat([]byte{FRAME_TYPE_UDP, 0, 0, 0, 0, 0, 0, 2}, []byte{5, 'a'})},
+		{"control json", concat([]byte{FRAME_TYPE_CONTROL, 0, 0, 0, 0, 0, 0, 1}, []byte{'{'})},
+	}
+	for _, tt := range tests {
+		codec := newBinaryCodec(bytes.NewReader(tt.frame), nil)
+		var msg Message
+		if err := codec.ReadMessage(&msg); err == nil {
+			t.Errorf("%s: frame was accepted: %+v", tt.name, msg)
+		}
+	}
+}
+
+// JSONのデコーダが先読みしたフレームも、バイナリに切り替えた後に取りこぼさず読めること
+func TestBinaryCodecAfterJSON(t *testing.T) {
+	var stream bytes.Buffer
+	login := Message{Type: "login_success", Codec: CODEC_BINARY}
+	if err := newJSONCodec(&stream, &stream).WriteMessage(&login); err != nil {
+		t.Fatal(err)
+	}
+	loginSize := stream.Len()
+	frames := []Message{
+		{Type: MSG_TYPE_NEW_CONN, ConnID: "c1", ProxyName: "tcp", RemoteAddr: "203.0.113.5:50000"},
+		{Type: MSG_TYPE_DATA, ConnID: "c1", Data: []byte("hello")},
+	}
+	stream.Write(encodeFrames(t, frames...))
+	conn := bytes.NewReader(stream.Bytes())
+
+	jc := newJSONCodec(conn, io.Discard)
+	var msg Message
+	if err := jc.ReadMessage(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Codec != CODEC_BINARY {
+		t.Fatalf("login response = %+v", msg)
+	}
+	// デコーダがログイン応答より先まで読んでいなければ、このテストは意味をなさない
+	if read := stream.Len() - conn.Len(); read <= loginSize {
+		t.Fatalf("JSON decoder read only %d bytes; the test needs frames buffered", read)
+	}
+
+	codec := newBinaryCodecAfterJSON(io.MultiReader(jc.buffered(), conn), nil)
+	for _, want := range frames {
+		var got Message
+		if err := codec.ReadMessage(&got); err != nil {
+			t.Fatalf("read %s: %v", want.Type, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("read %+v, want %+v", got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"QuickPort/internal/minecraft"
+	"errors"
+	"log"
+	"net"
+	"slices"
+	"time"
+)
+
+// 公開中の接続1本分の情報
+type ConnectionInfo struct {
+	ID         string
+	ProxyName  string
+	RemoteAddr string // プレイヤーのアドレス（リレーが対応していない場合は空）
+	Opened     time.Time
+	Minecraft  *minecraft.ConnInfo // Minecraftのハンドシェイクを解析できた場合のみ
+	BytesIn    uint64              // プレイヤーから受け取ったバイト数
+	BytesOut   uint64              // プレイヤーへ送ったバイト数
+}
+
+// PlayerName はログイン中のプレイヤー名を返す（分からない場合は空）
+func (i ConnectionInfo) PlayerName() string {
+	if i.Minecraft == nil {
+		return ""
+	}
+	return i.Minecraft.PlayerName
+}
+
+// inspectStream はプレイヤーから届いたデータを解析し、分かったことを記録する
+// 制御コネクションの読み込みgoroutineからのみ呼ばれる
+func (c *FRPClient) inspectStream(s *stream, data []byte) {
+	if !s.inspector.Feed(data) {
+		if s.inspector.Done() {
+			s.inspector = nil
+		}
+		return
+	}
+
+	info := s.inspector.Info()
+	if s.inspector.Done() {
+		s.inspector = nil
+	}
+
+	s.mutex.Lock()
+	s.minecraft = &info
+	s.mutex.Unlock()
+
+	from := remoteHost(s.remoteAddr)
+	switch {
+	case info.PlayerName != "":
+		log.Printf("%s joined from %s with %s (%s, via %s)", info.PlayerName, from, info.Version(), s.id, info.ServerAddress)
+	case info.NextState == minecraft.NextStateStatus:
+		log.Printf("Server list ping from %s with %s (%s, via %s)", from, info.Version(), s.id, info.ServerAddress)
+	}
+}
+
+// remoteHost はプレイヤーのアドレスからポート番号を除いたものを返す
+func remoteHost(remoteAddr string) string {
+	if remoteAddr == "" {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// info はストリームの現在の情報を返す
+func (s *stream) info() ConnectionInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	info := ConnectionInfo{
+		ID:         s.id,
+		ProxyName:  s.proxyName,
+		RemoteAddr: s.remoteAddr,
+		Opened:     s.opened,
+		BytesIn:    s.bytesIn.Load(),
+		BytesOut:   s.bytesOut.Load(),
+	}
+	if s.minecraft != nil {
+		mc := *s.minecraft
+		info.Minecraft = &mc
+	}
+	return info
+}
+
+// Connections は公開中の接続の一覧を接続した順に返す
+func (c *FRPClient) Connections() []ConnectionInfo {
+	c.mutex.RLock()
+	streams := make([]*stream, 0, len(c.localConns))
+	for _, s := range c.localConns {
+		streams = append(streams, s)
+	}
+	c.mutex.RUnlock()
+
+	infos := make([]ConnectionInfo, 0, len(streams))
+	for _, s := range streams {
+		infos = append(infos, s.info())
+	}
+	slices.SortFunc(infos, func(a, b ConnectionInfo) int {
+		return a.Opened.Compare(b.Opened)
+	})
+	return infos
+}
+
+var errConnectionNotFound = errors.New("connection not found")
+
+// Kick は指定した接続を切断する
+func (c *FRPClient) Kick(connID string) error {
+	c.mutex.RLock()
+	s, exists := c.localConns[connID]
+	c.mutex.RUnlock()
+
+	if !exists {
+		return errConnectionNotFound
+	}
+
+	log.Printf("Kicking connection %s from %s", connID, remoteHost(s.remoteAddr))
+	// ローカル接続を閉じると、forwardFromLocalがリレーへcloseを送って片付ける
+	s.close()
+	return nil
+}
@@ -1,13 +1,18 @@
 package core
 
 import (
-	"QuickPort/share"
-	"encoding/json"
+	"QuickPort/internal/acl"
+	"QuickPort/internal/minecraft"
+	"QuickPort/internal/traffic"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,6 +23,17 @@ const (
 	MSG_TYPE_DATA     = "data"
 	MSG_TYPE_CLOSE    = "close"
 	MSG_TYPE_KICK     = "kick"
+
+	MSG_TYPE_WINDOW_UPDATE = "window_update" // 受信ウィンドウの払い戻し
+	MSG_TYPE_PING          = "ping"          // 生存確認
+	MSG_TYPE_PONG          = "pong"          // 生存確認への応答
+	MSG_TYPE_UDP           = "udp_data"      // UDPプロキシのデータグラム（RemoteAddrで送信元を区別）
+)
+
+// ログイン時にやり取りする拡張機能
+const (
+	FEATURE_FLOW_CONTROL = "flow_control" // ストリームごとのウィンドウ制御
+	FEATURE_HEARTBEAT    = "heartbeat"    // ping/pongによる生存確認
 )
 
 // メッセージ構造体
@@ -29,6 +45,12 @@ type Message struct {
 	Token     string `json:"token,omitempty"`    // 新規: 認証トークン
 	ErrorMsg  string `json:"error_msg,omitempty"` // 新規: エラーメッセージ
 	TokenInfo *TokenInfo `json:"token_info,omitempty"` // 新規: トークン情報
+	Codecs    []string   `json:"codecs,omitempty"`     // ログイン時: クライアントが対応するエンコード方式
+	Codec     string     `json:"codec,omitempty"`      // ログイン応答: サーバが選択したエンコード方式
+	Features  []string   `json:"features,omitempty"`   // ログイン時: 対応する拡張機能 / 応答: 有効になった拡張機能
+	Window    int        `json:"window,omitempty"`     // WINDOW_UPDATE: 払い戻すバイト数
+	Timestamp int64      `json:"timestamp,omitempty"`  // PING/PONG: pingの送信時刻(UnixNano)
+	RemoteAddr string    `json:"remote_addr,omitempty"` // NEW_CONN/UDP: プレイヤーのアドレス
 }
 
 // トークン情報構造体（サーバーと同じ）
@@ -42,6 +64,7 @@ type TokenInfo struct {
 	ProtocolType   string    `json:"protocol_type"`
 	BandwidthLimit string    `json:"bandwidth_limit"`
 	RemotePort     int       `json:"remote_port"`      // 新規: リモートポート
+	Proxies        []ProxyConfig `json:"proxies,omitempty"` // 複数のプロキシを公開するトークンの場合の一覧
 }
 
 // プロキシ設定
@@ -51,86 +74,411 @@ type ProxyConfig struct {
 	LocalIP    string `json:"local_ip"`
 	LocalPort  int    `json:"local_port"`
 	RemotePort int    `json:"remote_port"`
+	// ローカル接続の先頭に付けるPROXYプロトコルのヘッダ（""/"v1"/"v2"）。クライアント側だけの設定
+	ProxyProtocol string `json:"-"`
+	// Minecraftサーバへのプロキシか（プレイヤー名による制限・接続の解析・オフライン応答の対象）。クライアント側だけの設定
+	Minecraft bool `json:"-"`
+}
+
+// クライアントの動作設定
+type Options struct {
+	TLS     TLSConfig     // 制御コネクションのTLS設定
+	Backoff BackoffConfig // 再接続の待ち時間（未設定の項目は既定値）
+	// ハートビートの間隔とタイムアウト（未設定の項目は既定値）
+	Heartbeat HeartbeatConfig
+	// リレーへの接続のタイムアウト（0なら無制限）
+	DialTimeout time.Duration
+	// プレイヤーが接続するホスト名（空なら quickport.natyosu.com）
+	PublicHost string
+	// トークンの最初のプロキシの転送先の上書き（空・0の項目はトークンの設定を使う）
+	LocalIP   string
+	LocalPort int
+	// ローカル設定で追加登録するプロキシ（トークンの設定と同じ名前なら転送先を上書きする）
+	Proxies []ProxyConfig
+	// UDPセッションを閉じるまでの無通信時間（0なら既定値）
+	UDPIdleTimeout time.Duration
+	// 接続の先頭のパケットをMinecraftのハンドシェイクとして解析し、プレイヤー名などを記録する
+	InspectMinecraft bool
+	// ローカルサーバに接続できないときにサーバーリストとログインへ返す内容（nilなら従来どおり切断する）
+	Offline *minecraft.OfflineConfig
+	// プレイヤーが来たときにローカルサーバを起動する仕組み（nilなら起動しない）
+	Waker LocalServerWaker
+	// IPアドレスとプレイヤー名によるアクセス制限（nilなら制限しない）
+	AccessList *acl.List
+	// 新しい接続の受け入れ制限（未設定の項目は既定値）
+	RateLimit RateLimitConfig
+	// トークンの帯域制限に合わせて転送速度を制限する
+	ShapeBandwidth bool
+	// 帯域制限の上書き（例: "10Mbps"、空ならトークンの BandwidthLimit を使う）
+	BandwidthLimit string
+	// 日ごとの通信量の記録（nilなら記録しない）
+	TrafficLog *traffic.Log
 }
 
 // FRPクライアント
 type FRPClient struct {
 	serverAddr     string
+	options        Options
 	serverConn     net.Conn
+	codec          codec         // 制御コネクションのエンコード方式
+	writer         *connWriter   // 制御コネクションへの唯一の書き込み口
 	token          string        // 新規: 認証トークン
 	proxies        []ProxyConfig
 	tokenInfo      *TokenInfo    // 新規: トークン情報
-	localConns     map[string]net.Conn
+	localConns     map[string]*stream
+	udpSessions    map[string]*udpSession // プロキシ名と送信元アドレスごとのUDPセッション
+	udpMutex       sync.Mutex
+	limiter        *connLimiter  // 新しい接続の受け入れ制限
+	bytesIn        atomic.Uint64 // プレイヤーから受け取ったバイト数
+	bytesOut       atomic.Uint64 // プレイヤーへ送ったバイト数
+	trafficMutex   sync.Mutex
+	flushedIn      uint64        // 記録ファイルへ書き出し済みのバイト数
+	flushedOut     uint64
+	bandwidthLimit atomic.Int64  // プランの帯域（1秒あたりのバイト数）
+	upShaper       atomic.Pointer[shaper]
+	downShaper     atomic.Pointer[shaper]
 	mutex          sync.RWMutex
-	reconnectDelay time.Duration
+	state          ConnState     // 接続状態（再接続の待機状況を含む）
+	flowControl    atomic.Bool   // リレーがウィンドウ制御に対応しているか
+	useHeartbeat   bool          // リレーがping/pongに対応しているか
+	heartbeat      *heartbeat    // 現在の制御コネクションの生存確認
+	rtt            atomic.Int64  // 直近のRTT(ナノ秒)
+	status         statusHub     // 画面などへ通知するトンネルの状態
+	cancel         context.CancelFunc // 実行中のStart()を止める（停止中はnil）
+	done           chan struct{}      // Start()が戻ると閉じられる
 }
 
+// 公開アドレスの既定のホスト名
+const defaultPublicHost = "quickport.natyosu.com"
+
+// 停止時にcloseを送り切るまでの待ち時間
+const shutdownTimeout = 3 * time.Second
+
+var (
+	errAlreadyStarted = errors.New("FRP client is already running")
+	errKicked         = errors.New("kicked by server")
+)
+
 func NewFRPClient(serverAddr, token string) *FRPClient {
+	return NewFRPClientWithOptions(serverAddr, token, Options{})
+}
+
+func NewFRPClientWithOptions(serverAddr, token string, options Options) *FRPClient {
 	return &FRPClient{
 		serverAddr:     serverAddr,
+		options:        options,
 		token:         token,
 		proxies:        []ProxyConfig{}, // 初期化時は空、認証後に設定
-		localConns:     make(map[string]net.Conn),
-		reconnectDelay: 60 * time.Second,
+		localConns:     make(map[string]*stream),
+		udpSessions:    make(map[string]*udpSession),
+		limiter:        newConnLimiter(options.RateLimit),
 	}
 }
 
-func (c *FRPClient) Start() error {
+// Start はリレーサーバに接続し、ctxがキャンセルされるかStop()が呼ばれるまで再接続を繰り返す
+// 停止による終了の場合はnilを返す
+func (c *FRPClient) Start(ctx context.Context) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mutex.Lock()
+	if c.cancel != nil {
+		c.mutex.Unlock()
+		cancel()
+		return errAlreadyStarted
+	}
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.mutex.Unlock()
+
+	c.status.update(func(s *Status) {
+		*s = Status{Running: true}
+	})
+	defer func() {
+		cancel()
+		c.mutex.Lock()
+		if c.state.Kind != StateGaveUp {
+			c.state = ConnState{Kind: StateIdle}
+		}
+		state := c.state
+		c.mutex.Unlock()
+		c.status.update(func(s *Status) {
+			s.Running = false
+			s.State = state
+			s.PublicAddr = ""
+			s.Route = ""
+			s.Proxies = nil
+			s.Kicked = errors.Is(err, errKicked)
+			if err != nil {
+				s.LastError = err.Error()
+			}
+		})
+		c.flushTraffic()
+
+		c.mutex.Lock()
+		c.cancel = nil
+		close(c.done)
+		c.mutex.Unlock()
+	}()
+
+	// キャンセルされたら接続中のストリームを片付けて、読み込み待ちを解除する
+	stopShutdown := context.AfterFunc(ctx, c.shutdown)
+	defer stopShutdown()
+
+	go c.runUDPExpiry(ctx.Done())
+	go c.runTrafficFlush(ctx.Done())
+	if c.options.AccessList != nil {
+		go c.options.AccessList.Watch(ctx.Done())
+	}
+
 	// 最初の接続試行
-	err := c.connect()
+	c.setState(ConnState{Kind: StateConnecting})
+	err = c.connect(ctx)
 	if err != nil {
-		return fmt.Errorf("初期接続に失敗しました: %v", err)
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.setState(ConnState{Kind: StateIdle, LastError: err.Error()})
+		return fmt.Errorf("初期接続に失敗しました: %w", err)
 	}
+	c.setState(ConnState{Kind: StateConnected})
 
 	// 接続成功後は再接続ループに入る
 	for {
 		err = c.handleConnection()
+		c.closeConnection()
+		if ctx.Err() != nil {
+			log.Printf("FRP client stopped")
+			return nil
+		}
+		if errors.Is(err, errKicked) {
+			return err
+		}
 		if err != nil {
 			log.Printf("Connection error: %v", err)
 		}
 
-		log.Printf("Disconnected from server. Retrying in %v...", c.reconnectDelay)
-		time.Sleep(c.reconnectDelay)
-		
 		// 再接続試行
-		err = c.connect()
-		if err != nil {
-			log.Printf("Reconnection failed: %v", err)
-			time.Sleep(c.reconnectDelay)
-			continue
+		if err := c.reconnect(ctx, err); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			log.Printf("FRP client stopped")
+			return nil
 		}
 	}
 }
 
-func (c *FRPClient) connect() error {
-	conn, err := net.Dial("tcp", c.serverAddr)
+// reconnect は接続できるまでバックオフしながら再接続を繰り返す
+// 停止された場合はnil、試行回数の上限に達した場合はエラーを返す
+func (c *FRPClient) reconnect(ctx context.Context, cause error) error {
+	backoff := c.options.Backoff.withDefaults()
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if backoff.MaxAttempts > 0 && attempt > backoff.MaxAttempts {
+			c.setState(ConnState{Kind: StateGaveUp, Attempt: attempt - 1, LastError: lastError})
+			return fmt.Errorf("%d回再接続に失敗したため接続を終了しました: %s", attempt-1, lastError)
+		}
+
+		if delay := backoff.delay(attempt); delay > 0 {
+			c.setState(ConnState{
+				Kind:        StateBackoff,
+				Attempt:     attempt,
+				NextAttempt: time.Now().Add(delay),
+				LastError:   lastError,
+			})
+			log.Printf("Retrying in %v (attempt %d)...", delay.Round(time.Millisecond), attempt)
+			if !sleepContext(ctx, delay) {
+				return nil
+			}
+		}
+
+		c.setState(ConnState{Kind: StateConnecting, Attempt: attempt, LastError: lastError})
+		err := c.connect(ctx)
+		if err == nil {
+			c.setState(ConnState{Kind: StateConnected})
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Reconnection failed: %v", err)
+		lastError = err.Error()
+	}
+}
+
+// State は現在の接続状態を返す
+func (c *FRPClient) State() ConnState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.state
+}
+
+func (c *FRPClient) setState(state ConnState) {
+	c.mutex.Lock()
+	c.state = state
+	c.mutex.Unlock()
+	c.status.update(func(s *Status) {
+		s.State = state
+	})
+	log.Printf("Connection state: %s", state)
+}
+
+// Stop は全ストリームに切断を通知してクライアントを停止し、Start()が戻るまで待つ
+func (c *FRPClient) Stop() {
+	c.mutex.RLock()
+	cancel, done := c.cancel, c.done
+	c.mutex.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// shutdown は接続中の全ストリームにcloseを送ってから閉じ、制御コネクションを切断する
+func (c *FRPClient) shutdown() {
+	c.mutex.RLock()
+	conn := c.serverConn
+	streams := make([]*stream, 0, len(c.localConns))
+	for _, s := range c.localConns {
+		streams = append(streams, s)
+	}
+	c.mutex.RUnlock()
+
+	if conn != nil {
+		// リレーが応答しない場合でも停止処理が止まらないようにする
+		conn.SetWriteDeadline(time.Now().Add(shutdownTimeout))
+	}
+	for _, s := range streams {
+		if err := c.notifyClose(s); err != nil {
+			break
+		}
+	}
+	c.closeAllStreams()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// sleepContext はdの間待つ。途中でキャンセルされた場合はfalseを返す
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *FRPClient) connect(ctx context.Context) error {
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return err
 	}
 
+	c.mutex.Lock()
 	c.serverConn = conn
+	c.mutex.Unlock()
+	if ctx.Err() != nil {
+		// 接続中に停止された場合、shutdownがこの接続を閉じられないのでここで閉じる
+		conn.Close()
+		return ctx.Err()
+	}
+
+	// ログインが完了するまではどのリレーサーバでも解釈できるJSONでやり取りする
+	c.codec = newJSONCodec(conn, conn)
 	log.Printf("Connected to FRP server at %s", c.serverAddr)
 
-	return c.login()
+	if err := c.login(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// ログイン後の書き込みはすべて送信ループを経由させる
+	c.mutex.Lock()
+	c.writer = newConnWriter(conn, c.codec)
+	c.mutex.Unlock()
+
+	c.rtt.Store(0)
+	if c.useHeartbeat {
+		// 古いリレーはpingに応答しないので、対応している場合のみ生存確認を行う
+		c.mutex.Lock()
+		c.heartbeat = c.startHeartbeat(conn)
+		c.mutex.Unlock()
+	}
+	return nil
+}
+
+// publicHost はプレイヤーが接続するホスト名を返す
+func (c *FRPClient) publicHost() string {
+	if c.options.PublicHost != "" {
+		return c.options.PublicHost
+	}
+	return defaultPublicHost
+}
+
+func (c *FRPClient) dial(ctx context.Context) (net.Conn, error) {
+	if c.options.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.DialTimeout)
+		defer cancel()
+	}
+	if c.options.TLS.Enabled {
+		return dialTLS(ctx, c.serverAddr, c.options.TLS)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", c.serverAddr)
+}
+
+// 制御コネクションと送信ループを閉じる
+func (c *FRPClient) closeConnection() {
+	c.mutex.Lock()
+	writer := c.writer
+	conn := c.serverConn
+	hb := c.heartbeat
+	c.writer = nil
+	c.serverConn = nil
+	c.heartbeat = nil
+	c.mutex.Unlock()
+
+	if hb != nil {
+		hb.Stop()
+	}
+	if writer != nil {
+		writer.Close()
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	c.closeAllStreams()
+	c.closeAllUDPSessions()
 }
 
 func (c *FRPClient) login() error {
-	// 初期ログインでは空のプロキシ設定を送信（トークン認証のみ）
+	// ローカル設定のプロキシがあれば登録を要求する（無ければ空のJSONでトークン認証のみ）
 	msg := Message{
-		Type:  MSG_TYPE_LOGIN,
-		Token: c.token,
-		Data:  []byte("{}"), // 空のJSON
+		Type:     MSG_TYPE_LOGIN,
+		Token:    c.token,
+		Data:     c.loginPayload(),
+		Codecs:   []string{CODEC_BINARY, CODEC_JSON},
+		Features: []string{FEATURE_FLOW_CONTROL, FEATURE_HEARTBEAT},
 	}
 
-	encoder := json.NewEncoder(c.serverConn)
-	if err := encoder.Encode(msg); err != nil {
+	if err := c.codec.WriteMessage(&msg); err != nil {
 		return err
 	}
 
 	// ログイン応答を待つ
-	decoder := json.NewDecoder(c.serverConn)
 	var response Message
-	if err := decoder.Decode(&response); err != nil {
+	if err := c.codec.ReadMessage(&response); err != nil {
 		return err
 	}
 
@@ -141,7 +489,8 @@ func (c *FRPClient) login() error {
 			c.tokenInfo = response.TokenInfo
 			
 			// トークン情報からプロキシ設定を構築
-			c.buildProxyFromTokenInfo()
+			c.buildProxiesFromTokenInfo()
+			c.setupShaping()
 			
 			log.Printf("Login successful with token info:")
 			log.Printf("  Email: %s", c.tokenInfo.Email)
@@ -151,15 +500,24 @@ func (c *FRPClient) login() error {
 			log.Printf("  Bandwidth: %s", c.tokenInfo.BandwidthLimit)
 		}
 
-		share.IsConnection = true // 接続状態を更新
-		share.PublicAddr = fmt.Sprintf("quickport.natyosu.com:%d", c.GetPublicPort())
-		share.Route = fmt.Sprintf("localhost:%d <-----> quickport.natyosu.com:%d", c.GetLocalPort(), c.GetPublicPort())
+		// サーバがバイナリフレームに対応していれば以降はそちらに切り替える
+		c.negotiateCodec(response.Codec)
+		c.flowControl.Store(slices.Contains(response.Features, FEATURE_FLOW_CONTROL))
+		c.useHeartbeat = slices.Contains(response.Features, FEATURE_HEARTBEAT)
+		log.Printf("Flow control: %v, Heartbeat: %v", c.flowControl.Load(), c.useHeartbeat)
 
-		log.Printf("Configured %d proxies from token", len(c.proxies))
+		proxies := c.Proxies()
+		c.status.update(func(s *Status) {
+			s.PublicAddr = fmt.Sprintf("%s:%d", c.publicHost(), c.GetPublicPort())
+			s.Route = routeText(c.publicHost(), proxies)
+			s.Proxies = proxies
+		})
+
+		log.Printf("Configured %d proxies from token", len(proxies))
 		if len(response.Data) > 0 {
 			log.Printf("Server message: %s", string(response.Data))
 		}
-		for _, proxy := range c.proxies {
+		for _, proxy := range proxies {
 			log.Printf("  - %s: %s:%d -> :%d", proxy.Name, proxy.LocalIP, proxy.LocalPort, proxy.RemotePort)
 		}
 		return nil
@@ -170,125 +528,207 @@ func (c *FRPClient) login() error {
 	return fmt.Errorf("login failed")
 }
 
-// トークン情報からプロキシ設定を構築
-func (c *FRPClient) buildProxyFromTokenInfo() {
-	if c.tokenInfo == nil {
+// ログイン応答で選択されたエンコード方式に切り替える
+// 古いリレーサーバはCodecを返さないので、その場合はJSONのまま
+func (c *FRPClient) negotiateCodec(selected string) {
+	current, ok := c.codec.(*jsonCodec)
+	if !ok || selected != CODEC_BINARY {
+		log.Printf("Using %s framing", c.codec.Name())
 		return
 	}
-	
-	proxy := ProxyConfig{
-		Name:       c.tokenInfo.ProtocolType,
-		Type:       c.tokenInfo.ProtocolType,
-		LocalIP:    c.tokenInfo.LocalIP,
-		LocalPort:  c.tokenInfo.LocalPort,
-		RemotePort: c.tokenInfo.RemotePort,
-	}
-	
-	c.proxies = []ProxyConfig{proxy}
-	
-	log.Printf("Built proxy config from token: %s:%d -> :%d", 
-		proxy.LocalIP, proxy.LocalPort, proxy.RemotePort)
+
+	// JSONデコーダが先読みしたデータを取りこぼさないように引き継ぐ
+	c.codec = newBinaryCodecAfterJSON(io.MultiReader(current.buffered(), c.serverConn), c.serverConn)
+	log.Printf("Using %s framing", c.codec.Name())
 }
 
 func (c *FRPClient) handleConnection() error {
-	decoder := json.NewDecoder(c.serverConn)
+	c.mutex.RLock()
+	hb := c.heartbeat
+	c.mutex.RUnlock()
 
 	for {
 		var msg Message
-		if err := decoder.Decode(&msg); err != nil {
+		if err := c.codec.ReadMessage(&msg); err != nil {
+			if hb != nil && hb.timedOut.Load() {
+				return errHeartbeatTimeout
+			}
 			if err == io.EOF {
 				return fmt.Errorf("server closed connection")
 			}
 			return err
 		}
+		if hb != nil {
+			hb.touch()
+		}
 
 		switch msg.Type {
 		case MSG_TYPE_NEW_CONN:
+			// 接続の洪水でgoroutineとローカルへの接続が増え続けないように、ここで間引く
+			if reason, ok := c.limiter.allow(msg.RemoteAddr); !ok {
+				go c.sendCloseMessageWithReason(msg.ConnID, reason)
+				continue
+			}
 			go c.handleNewConnection(&msg)
 		case MSG_TYPE_DATA:
 			c.handleData(&msg)
 		case MSG_TYPE_CLOSE:
 			c.handleClose(&msg)
+		case MSG_TYPE_WINDOW_UPDATE:
+			c.handleWindowUpdate(&msg)
+		case MSG_TYPE_PING:
+			c.handlePing(&msg)
+		case MSG_TYPE_PONG:
+			c.handlePong(&msg)
+		case MSG_TYPE_UDP:
+			c.handleUDPData(&msg)
 		case MSG_TYPE_KICK:
-			share.IsConnection = false // 接続状態を更新
-			share.IsRunningFrpc = false // FRPクライアントを停止
 			log.Printf("Received kick message from server. Disconnecting...")
-			return fmt.Errorf("kicked by server")
+			// キックされた場合は再接続せずにクライアントを停止する
+			return errKicked
 		}
 	}
 }
 
 func (c *FRPClient) handleNewConnection(msg *Message) {
 	// プロキシ設定を見つける
-	var proxyConfig *ProxyConfig
-	for _, proxy := range c.proxies {
-		if proxy.Name == msg.ProxyName {
-			proxyConfig = &proxy
-			break
-		}
+	proxyConfig, ok := c.findProxy(msg.ProxyName)
+	if !ok {
+		log.Printf("Unknown proxy: %s", msg.ProxyName)
+		c.sendCloseMessage(msg.ConnID)
+		return
 	}
 
-	if proxyConfig == nil {
-		log.Printf("Unknown proxy: %s", msg.ProxyName)
+	// ローカルサービスへ接続する前にアクセス制限を確かめる
+	rules := c.accessRules()
+	if reason, ok := rules.CheckAddr(msg.RemoteAddr); !ok {
+		c.limiter.denied()
+		c.rejectConnection(msg, reason)
 		return
 	}
 
-	// ローカルサービスに接続
-	localAddr := net.JoinHostPort(proxyConfig.LocalIP, fmt.Sprintf("%d", proxyConfig.LocalPort))
-	localConn, err := net.Dial("tcp", localAddr)
-	if err != nil {
-		log.Printf("Failed to connect to local service %s: %v", localAddr, err)
-		c.sendCloseMessage(msg.ConnID)
+	c.mutex.RLock()
+	reason, ok := c.checkStreamLimits(msg.RemoteAddr)
+	c.mutex.RUnlock()
+	if !ok {
+		c.rejectConnection(msg, reason)
 		return
 	}
 
+	localAddr := net.JoinHostPort(proxyConfig.LocalIP, fmt.Sprintf("%d", proxyConfig.LocalPort))
+	var localConn net.Conn
+	offline := false
+	if proxyConfig.Minecraft && rules.HasPlayerRules() {
+		// プレイヤー名はログイン開始を受け取るまで分からないので、確かめてから接続する
+		localConn = c.gateByPlayer(proxyConfig, msg, rules)
+	} else {
+		var err error
+		localConn, offline, err = c.openLocal(proxyConfig, msg.RemoteAddr)
+		if err != nil {
+			log.Printf("Failed to connect to local service %s: %v", localAddr, err)
+			c.sendCloseMessage(msg.ConnID)
+			return
+		}
+	}
+
+	s := newStream(msg.ConnID, localConn)
+	s.proxyName = msg.ProxyName
+	s.remoteAddr = msg.RemoteAddr
+	if c.options.InspectMinecraft && proxyConfig.Minecraft && !offline {
+		s.inspector = minecraft.NewInspector()
+	}
+	s.offline = offline
 	c.mutex.Lock()
-	c.localConns[msg.ConnID] = localConn
+	// 接続している間に他の接続で上限に達していないか、登録する直前にもう一度確かめる
+	if reason, ok := c.checkStreamLimits(msg.RemoteAddr); !ok {
+		c.mutex.Unlock()
+		localConn.Close()
+		c.rejectConnection(msg, reason)
+		return
+	}
+	c.localConns[msg.ConnID] = s
 	c.mutex.Unlock()
+	c.reportConnections()
 
-	log.Printf("New proxy connection %s: %s -> %s", msg.ConnID, msg.ProxyName, localAddr)
+	log.Printf("New proxy connection %s from %s: %s -> %s", msg.ConnID, msg.RemoteAddr, msg.ProxyName, localAddr)
 
+	// リレーからのデータはストリームごとのgoroutineでローカルへ書き込む
+	go c.writeToLocal(s)
 	// ローカル接続からのデータを読み取り、サーバーに転送
-	go c.forwardFromLocal(localConn, msg.ConnID)
+	go c.forwardFromLocal(s)
+}
+
+// openLocal はローカルサービスに接続する
+// 接続できない場合にオフライン応答が設定されていれば、代わりに応対する仮想的な接続を返す（offline=true）
+func (c *FRPClient) openLocal(proxyConfig ProxyConfig, remoteAddr string) (net.Conn, bool, error) {
+	localAddr := net.JoinHostPort(proxyConfig.LocalIP, fmt.Sprintf("%d", proxyConfig.LocalPort))
+	localConn, err := net.Dial("tcp", localAddr)
+	if err != nil {
+		if !proxyConfig.Minecraft || (c.options.Offline == nil && c.options.Waker == nil) {
+			return nil, false, err
+		}
+		// サーバが止まっていることをプレイヤーに伝えるため、代わりに応対する
+		log.Printf("Local service %s is down, answering with the offline MOTD: %v", localAddr, err)
+		return c.serveOffline(remoteAddr, localAddr), true, nil
+	}
+
+	// プレイヤーの本当のアドレスをローカルサーバへ伝える
+	if proxyConfig.ProxyProtocol != "" {
+		if err := writeProxyHeader(localConn, proxyConfig.ProxyProtocol, remoteAddr); err != nil {
+			localConn.Close()
+			return nil, false, fmt.Errorf("failed to write PROXY protocol header: %v", err)
+		}
+	}
+	return localConn, false, nil
 }
 
-func (c *FRPClient) forwardFromLocal(localConn net.Conn, connID string) {
+func (c *FRPClient) forwardFromLocal(s *stream) {
 	defer func() {
-		localConn.Close()
+		if name := s.info().PlayerName(); name != "" {
+			log.Printf("%s left (%s)", name, s.id)
+		}
+		s.close()
 		c.mutex.Lock()
-		delete(c.localConns, connID)
+		if c.localConns[s.id] == s {
+			delete(c.localConns, s.id)
+		}
 		c.mutex.Unlock()
-		c.sendCloseMessage(connID)
+		c.reportConnections()
+		c.notifyClose(s)
 	}()
 
-	buffer := make([]byte, 4096)
-	for {
-		n, err := localConn.Read(buffer)
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("Local connection read error: %v", err)
-			}
-			break
-		}
-
-		c.sendDataMessage(connID, buffer[:n])
+	if err := c.readFromLocal(s); err != nil {
+		log.Printf("Local connection read error: %v", err)
 	}
 }
 
 func (c *FRPClient) handleData(msg *Message) {
 	c.mutex.RLock()
-	localConn, exists := c.localConns[msg.ConnID]
+	s, exists := c.localConns[msg.ConnID]
 	c.mutex.RUnlock()
 
-	if exists {
-		localConn.Write(msg.Data)
+	if !exists {
+		return
+	}
+
+	if s.inspector != nil {
+		c.inspectStream(s, msg.Data)
+	}
+	s.bytesIn.Add(uint64(len(msg.Data)))
+	c.countIn(len(msg.Data))
+
+	if err := s.enqueue(msg.Data, c.flowControl.Load()); err != nil {
+		// ウィンドウを超えて送ってくるのはリレー側の不具合で、古いリレーではローカルへの書き込みが追いつかない状態なので、
+		// リレーからの読み込みを止めずにこのストリームだけ切断する
+		log.Printf("Dropping connection %s: %v", msg.ConnID, err)
+		s.close()
 	}
 }
 
 func (c *FRPClient) handleClose(msg *Message) {
 	c.mutex.Lock()
-	if localConn, exists := c.localConns[msg.ConnID]; exists {
-		localConn.Close()
+	if s, exists := c.localConns[msg.ConnID]; exists {
+		s.finish()
 		delete(c.localConns, msg.ConnID)
 	}
 	c.mutex.Unlock()
@@ -296,35 +736,62 @@ func (c *FRPClient) handleClose(msg *Message) {
 	log.Printf("Connection %s closed", msg.ConnID)
 }
 
-func (c *FRPClient) sendDataMessage(connID string, data []byte) {
+// 送信ループにメッセージを渡し、書き込みの完了を待つ
+func (c *FRPClient) send(msg *Message) error {
+	c.mutex.RLock()
+	writer := c.writer
+	c.mutex.RUnlock()
+
+	if writer == nil {
+		return errWriterClosed
+	}
+	return writer.Send(msg)
+}
+
+func (c *FRPClient) sendDataMessage(connID string, data []byte) error {
 	msg := Message{
 		Type:   MSG_TYPE_DATA,
 		ConnID: connID,
 		Data:   data,
 	}
 
-	encoder := json.NewEncoder(c.serverConn)
-	encoder.Encode(msg)
+	return c.send(&msg)
 }
 
-func (c *FRPClient) sendCloseMessage(connID string) {
+func (c *FRPClient) sendCloseMessage(connID string) error {
 	msg := Message{
 		Type:   MSG_TYPE_CLOSE,
 		ConnID: connID,
 	}
 
-	encoder := json.NewEncoder(c.serverConn)
-	encoder.Encode(msg)
+	return c.send(&msg)
+}
+
+// sendCloseMessageWithReason は切断の理由を添えてcloseを送る
+func (c *FRPClient) sendCloseMessageWithReason(connID, reason string) error {
+	msg := Message{
+		Type:     MSG_TYPE_CLOSE,
+		ConnID:   connID,
+		ErrorMsg: reason,
+	}
+
+	return c.send(&msg)
 }
 
+// GetLocalPort は最初のプロキシのローカルポートを返す（全体はProxies()を参照）
 func (c *FRPClient) GetLocalPort() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	if len(c.proxies) > 0 {
 		return c.proxies[0].LocalPort
 	}
 	return 0 // プロキシが設定されていない場合は0を返す
 }
 
+// GetPublicPort は最初のプロキシの公開ポートを返す（全体はProxies()を参照）
 func (c *FRPClient) GetPublicPort() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	if len(c.proxies) > 0 {
 		return c.proxies[0].RemotePort
 	}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ハートビートの設定
+type HeartbeatConfig struct {
+	Interval time.Duration // pingを送る間隔
+	Timeout  time.Duration // この時間リレーから何も届かなければ接続が切れたとみなす
+}
+
+func DefaultHeartbeatConfig() HeartbeatConfig {
+	return HeartbeatConfig{
+		Interval: 15 * time.Second,
+		Timeout:  45 * time.Second,
+	}
+}
+
+func (h HeartbeatConfig) withDefaults() HeartbeatConfig {
+	def := DefaultHeartbeatConfig()
+	if h.Interval <= 0 {
+		h.Interval = def.Interval
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = def.Timeout
+	}
+	if h.Timeout < h.Interval {
+		// pingの応答を待たずに切断しないようにする
+		h.Timeout = 2 * h.Interval
+	}
+	return h
+}
+
+var errHeartbeatTimeout = errors.New("heartbeat timeout: no response from server")
+
+// heartbeat は1本の制御コネクションの生存確認を行う
+// NATのマッピングが黙って消えた場合など、読み込みが永遠に返らない状態を検出して接続を閉じる
+type heartbeat struct {
+	conn     net.Conn
+	config   HeartbeatConfig
+	lastSeen atomic.Int64 // 最後にリレーから何か届いた時刻(UnixNano)
+	timedOut atomic.Bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (c *FRPClient) startHeartbeat(conn net.Conn) *heartbeat {
+	h := &heartbeat{
+		conn:   conn,
+		config: c.options.Heartbeat.withDefaults(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	h.touch()
+	go c.runHeartbeat(h)
+	return h
+}
+
+// touch はリレーからメッセージが届いたことを記録する
+func (h *heartbeat) touch() {
+	h.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (h *heartbeat) Stop() {
+	close(h.stop)
+	<-h.done
+}
+
+func (c *FRPClient) runHeartbeat(h *heartbeat) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case now := <-ticker.C:
+			silence := now.Sub(time.Unix(0, h.lastSeen.Load()))
+			if silence > h.config.Timeout {
+				log.Printf("No response from server for %v. Closing connection...", silence.Round(time.Millisecond))
+				h.timedOut.Store(true)
+				// 読み込み待ちを解除して再接続に進ませる
+				h.conn.Close()
+				return
+			}
+
+			// 送信ループが詰まっていても次のtickで判定できるように、待たずに送る
+			go func() {
+				if err := c.sendPing(now); err != nil {
+					log.Printf("Failed to send ping: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+func (c *FRPClient) sendPing(now time.Time) error {
+	msg := Message{
+		Type:      MSG_TYPE_PING,
+		Timestamp: now.UnixNano(),
+	}
+
+	return c.send(&msg)
+}
+
+func (c *FRPClient) handlePing(msg *Message) {
+	// リレーからのpingには送信時刻をそのまま返す
+	pong := Message{
+		Type:      MSG_TYPE_PONG,
+		Timestamp: msg.Timestamp,
+	}
+	go c.send(&pong)
+}
+
+func (c *FRPClient) handlePong(msg *Message) {
+	if msg.Timestamp == 0 {
+		return
+	}
+
+	rtt := time.Since(time.Unix(0, msg.Timestamp))
+	if rtt < 0 {
+		return
+	}
+	c.rtt.Store(int64(rtt))
+}
+
+// RTT は直近のping/pongで測定した往復時間を返す（未測定の場合は0）
+func (c *FRPClient) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
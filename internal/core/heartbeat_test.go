@@ -0,0 +1,120 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// gatedListener は2本目以降の接続を、gate に値が送られるまで受け入れない
+// 受け入れるまでクライアントはログインの応答を待ち続けるので、再接続中の状態を確かめられる
+type gatedListener struct {
+	net.Listener
+	gate chan struct{}
+}
+
+func newGatedListener(t *testing.T) *gatedListener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &gatedListener{Listener: listener, gate: make(chan struct{}, 1)}
+	l.gate <- struct{}{}
+	t.Cleanup(func() { close(l.gate) })
+	return l
+}
+
+func (l *gatedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	<-l.gate
+	return conn, nil
+}
+
+// waitFor は cond が true になるまで待つ
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// pingを送ってもリレーから何も届かなければ、ハートビートのタイムアウトで切断して再接続すること
+func TestHeartbeatTimeoutReconnects(t *testing.T) {
+	listener := newGatedListener(t)
+	relay := newFakeRelay(t, listener, TokenInfo{ProtocolType: PROXY_TYPE_TCP, LocalPort: 25565, RemotePort: 40000}, FEATURE_HEARTBEAT)
+	client := startClient(t, relay.Addr(), Options{
+		Heartbeat: HeartbeatConfig{Interval: 20 * time.Millisecond, Timeout: 100 * time.Millisecond},
+	})
+	rc := relay.accept()
+
+	// pingには応答しない
+	if msg := rc.next(); msg.Type != MSG_TYPE_PING || msg.Timestamp == 0 {
+		t.Fatalf("first message = %+v, want a ping", msg)
+	}
+	for open := true; open; {
+		select {
+		case _, open = <-rc.messages:
+		case <-time.After(testTimeout):
+			t.Fatal("client kept a silent connection open")
+		}
+	}
+
+	waitFor(t, "a reconnect caused by the heartbeat timeout", func() bool {
+		state := client.State()
+		return state.Kind == StateConnecting && state.LastError == errHeartbeatTimeout.Error()
+	})
+	listener.gate <- struct{}{}
+	relay.accept()
+	waitFor(t, "the client to reconnect", func() bool {
+		return client.State().Kind == StateConnected
+	})
+}
+
+// PONGを受け取ったら、対応するpingからの往復時間をRTTにすること
+func TestHeartbeatPongUpdatesRTT(t *testing.T) {
+	relay := newFakeRelay(t, nil, TokenInfo{ProtocolType: PROXY_TYPE_TCP, LocalPort: 25565, RemotePort: 40000}, FEATURE_HEARTBEAT)
+	client := startClient(t, relay.Addr(), Options{
+		Heartbeat: HeartbeatConfig{Interval: 20 * time.Millisecond, Timeout: testTimeout},
+	})
+	rc := relay.accept()
+
+	ping := rc.next()
+	if ping.Type != MSG_TYPE_PING {
+		t.Fatalf("first message = %+v, want a ping", ping)
+	}
+	if rtt := client.RTT(); rtt != 0 {
+		t.Fatalf("RTT = %v before any pong", rtt)
+	}
+
+	const delay = 30 * time.Millisecond
+	time.Sleep(delay)
+	rc.send(Message{Type: MSG_TYPE_PONG, Timestamp: ping.Timestamp})
+	waitFor(t, "RTT to be measured", func() bool {
+		return client.RTT() >= delay
+	})
+	if rtt := client.RTT(); rtt > testTimeout {
+		t.Fatalf("RTT = %v, want about %v", rtt, delay)
+	}
+}
+
+// リレーからのpingには同じ時刻を付けたPONGを返すこと
+func TestHeartbeatAnswersPing(t *testing.T) {
+	relay := newFakeRelay(t, nil, TokenInfo{ProtocolType: PROXY_TYPE_TCP, LocalPort: 25565, RemotePort: 40000}, FEATURE_HEARTBEAT)
+	startClient(t, relay.Addr(), Options{
+		Heartbeat: HeartbeatConfig{Interval: time.Hour},
+	})
+	rc := relay.accept()
+
+	rc.send(Message{Type: MSG_TYPE_PING, Timestamp: 12345})
+	if msg := rc.next(); msg.Type != MSG_TYPE_PONG || msg.Timestamp != 12345 {
+		t.Fatalf("reply = %+v, want a pong with the ping timestamp", msg)
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"QuickPort/internal/minecraft"
+	"log"
+	"net"
+)
+
+// LocalServerWaker はプレイヤーが来たときにローカルサーバを起動する仕組み
+// internal/supervisor.Supervisor が実装する
+type LocalServerWaker interface {
+	// Wake はローカルサーバが停止中にプレイヤーがログインしようとしたときに呼ばれる
+	// localAddr は接続に失敗したローカルサーバのアドレス
+	Wake(localAddr string)
+	// Starting は起動が完了するまでの間trueを返す
+	Starting() bool
+	// SetConnections はローカルサーバへの接続数が変わるたびに呼ばれる
+	SetConnections(n int)
+}
+
+// serveOffline はローカルサーバの代わりにオフライン時の応答を返す仮想的な接続を作る
+// 戻り値はローカルサービスへの接続と同じようにストリームに渡せる
+func (c *FRPClient) serveOffline(remoteAddr, localAddr string) net.Conn {
+	local, responder := net.Pipe()
+	config := c.offlineConfig(localAddr)
+
+	go func() {
+		info, err := minecraft.ServeOffline(responder, config)
+		if err != nil {
+			log.Printf("Offline responder error for %s: %v", remoteHost(remoteAddr), err)
+			return
+		}
+		if info.PlayerName != "" {
+			log.Printf("%s tried to join from %s with %s while the server is offline",
+				info.PlayerName, remoteHost(remoteAddr), info.Version())
+		}
+	}()
+
+	return local
+}
+
+// offlineConfig はオフライン応答の内容を決める
+// ローカルサーバを起動できる場合は、ログインをきっかけに起動して「起動中」と表示する
+func (c *FRPClient) offlineConfig(localAddr string) minecraft.OfflineConfig {
+	config := minecraft.DefaultOfflineConfig()
+	if c.options.Offline != nil {
+		config = *c.options.Offline
+	}
+
+	waker := c.options.Waker
+	if waker == nil {
+		return config
+	}
+
+	if waker.Starting() {
+		config.MOTD = config.StartingMOTD
+	}
+	startingMessage := config.StartingMessage
+	config.OnLogin = func(info minecraft.ConnInfo) string {
+		waker.Wake(localAddr)
+		return startingMessage
+	}
+	return config
+}
+
+// reportConnections はローカルサーバへの接続数をWakerへ伝える
+// オフライン応答中の接続は数えない
+func (c *FRPClient) reportConnections() {
+	if c.options.Waker == nil {
+		return
+	}
+
+	c.mutex.RLock()
+	count := 0
+	for _, s := range c.localConns {
+		if !s.offline {
+			count++
+		}
+	}
+	c.mutex.RUnlock()
+
+	c.options.Waker.SetConnections(count)
+}
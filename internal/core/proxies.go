@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ログイン時にDataへ載せる登録要求
+// 空の場合は"{}"になり、従来どおりトークンの設定だけで公開する
+type LoginRequest struct {
+	Proxies []ProxyConfig `json:"proxies,omitempty"`
+}
+
+func (c *FRPClient) loginPayload() []byte {
+	data, err := json.Marshal(LoginRequest{Proxies: c.options.Proxies})
+	if err != nil {
+		return []byte("{}")
+	}
+	return data
+}
+
+// トークン情報からプロキシ設定を構築
+//
+// トークンにプロキシの一覧が含まれていればそれを使い、無ければ従来の単一の設定を使う。
+// Options.LocalIP/LocalPort は最初のプロキシの転送先を上書きする。
+// ローカル設定に同じ名前のプロキシがあれば、転送先(LocalIP/LocalPort)とPROXYプロトコルの設定はさらにそちらを優先する。
+// 最初のTCPのプロキシはMinecraftサーバとして扱い、それ以外はローカル設定で Minecraft を指定したものだけを扱う
+func (c *FRPClient) buildProxiesFromTokenInfo() {
+	if c.tokenInfo == nil {
+		return
+	}
+
+	var proxies []ProxyConfig
+	if len(c.tokenInfo.Proxies) > 0 {
+		proxies = append(proxies, c.tokenInfo.Proxies...)
+	} else {
+		proxies = append(proxies, ProxyConfig{
+			Name:       c.tokenInfo.ProtocolType,
+			Type:       c.tokenInfo.ProtocolType,
+			LocalIP:    c.tokenInfo.LocalIP,
+			LocalPort:  c.tokenInfo.LocalPort,
+			RemotePort: c.tokenInfo.RemotePort,
+		})
+	}
+
+	if c.options.LocalIP != "" {
+		proxies[0].LocalIP = c.options.LocalIP
+	}
+	if c.options.LocalPort != 0 {
+		proxies[0].LocalPort = c.options.LocalPort
+	}
+
+	for i := range proxies {
+		proxy := &proxies[i]
+		if proxy.Type == "" {
+			proxy.Type = c.tokenInfo.ProtocolType
+		}
+		if proxy.Name == "" {
+			proxy.Name = proxy.Type
+		}
+		if proxy.LocalIP == "" {
+			proxy.LocalIP = "127.0.0.1"
+		}
+		proxy.Minecraft = i == 0 && proxy.Type == PROXY_TYPE_TCP
+
+		for _, local := range c.options.Proxies {
+			if local.Name != proxy.Name {
+				continue
+			}
+			if local.LocalIP != "" {
+				proxy.LocalIP = local.LocalIP
+			}
+			if local.LocalPort != 0 {
+				proxy.LocalPort = local.LocalPort
+			}
+			proxy.ProxyProtocol = local.ProxyProtocol
+			if local.Minecraft {
+				proxy.Minecraft = true
+			}
+		}
+
+		log.Printf("Built proxy config from token: %s %s:%d -> :%d",
+			proxy.Name, proxy.LocalIP, proxy.LocalPort, proxy.RemotePort)
+	}
+
+	// ローカル設定にあってもリレーに受け付けられなかったものは転送できない
+	for _, local := range c.options.Proxies {
+		if !containsProxy(proxies, local.Name) {
+			log.Printf("Proxy %s was not accepted by the server", local.Name)
+		}
+	}
+
+	c.mutex.Lock()
+	c.proxies = proxies
+	c.mutex.Unlock()
+}
+
+func containsProxy(proxies []ProxyConfig, name string) bool {
+	for _, proxy := range proxies {
+		if proxy.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findProxy は名前に一致するプロキシ設定を返す
+func (c *FRPClient) findProxy(name string) (ProxyConfig, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, proxy := range c.proxies {
+		if proxy.Name == name {
+			return proxy, true
+		}
+	}
+	return ProxyConfig{}, false
+}
+
+// Proxies は現在公開中のプロキシ設定の一覧を返す
+func (c *FRPClient) Proxies() []ProxyConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]ProxyConfig(nil), c.proxies...)
+}
+
+// routeText は公開中の全プロキシの対応を1行ずつ表す
+func routeText(publicHost string, proxies []ProxyConfig) string {
+	lines := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		localHost := "localhost"
+		if proxy.LocalIP != "" && proxy.LocalIP != "127.0.0.1" {
+			localHost = proxy.LocalIP
+		}
+		line := fmt.Sprintf("%s:%d <-----> %s:%d", localHost, proxy.LocalPort, publicHost, proxy.RemotePort)
+		if len(proxies) > 1 {
+			line += fmt.Sprintf(" (%s/%s)", proxy.Name, proxy.Type)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
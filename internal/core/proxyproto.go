@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// PROXYプロトコルのバージョン
+const (
+	PROXY_PROTOCOL_V1 = "v1"
+	PROXY_PROTOCOL_V2 = "v2"
+)
+
+// PROXYプロトコルv2のシグネチャ
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ParseProxyProtocol は設定値をPROXYプロトコルのバージョンに正規化する
+// 空文字・"off"は無効、"1"/"v1"・"2"/"v2"はそれぞれのバージョンとして扱う
+func ParseProxyProtocol(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "off", "false", "none":
+		return "", nil
+	case "1", "v1":
+		return PROXY_PROTOCOL_V1, nil
+	case "2", "v2":
+		return PROXY_PROTOCOL_V2, nil
+	}
+	return "", fmt.Errorf("不明なPROXYプロトコルのバージョンです: %s", value)
+}
+
+// writeProxyHeader はローカル接続の先頭にPROXYプロトコルのヘッダを書き込む
+//
+// 送信元はリレーから届いたプレイヤーのアドレス、宛先はローカルサーバのアドレスとする。
+// 古いリレーでプレイヤーのアドレスが分からない場合は、v1はUNKNOWN・v2はLOCALとして送り、
+// 受け取った側が接続元のアドレスをそのまま使うようにする
+func writeProxyHeader(conn net.Conn, version, remoteAddr string) error {
+	src, srcErr := netip.ParseAddrPort(remoteAddr)
+	dst, dstErr := netip.ParseAddrPort(conn.RemoteAddr().String())
+	known := srcErr == nil && dstErr == nil
+
+	if known {
+		src = netip.AddrPortFrom(src.Addr().Unmap(), src.Port())
+		dst = netip.AddrPortFrom(dst.Addr().Unmap(), dst.Port())
+		// 送信元と宛先のアドレスファミリーを揃える
+		if src.Addr().Is4() != dst.Addr().Is4() {
+			src = netip.AddrPortFrom(netip.AddrFrom16(src.Addr().As16()), src.Port())
+			dst = netip.AddrPortFrom(netip.AddrFrom16(dst.Addr().As16()), dst.Port())
+		}
+	}
+
+	var header []byte
+	switch version {
+	case PROXY_PROTOCOL_V1:
+		header = proxyHeaderV1(src, dst, known)
+	case PROXY_PROTOCOL_V2:
+		header = proxyHeaderV2(src, dst, known)
+	default:
+		return fmt.Errorf("unsupported PROXY protocol version: %s", version)
+	}
+
+	_, err := conn.Write(header)
+	return err
+}
+
+func proxyHeaderV1(src, dst netip.AddrPort, known bool) []byte {
+	if !known {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	family := "TCP4"
+	if !src.Addr().Is4() {
+		family = "TCP6"
+	}
+	return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n",
+		family, src.Addr(), dst.Addr(), src.Port(), dst.Port())
+}
+
+func proxyHeaderV2(src, dst netip.AddrPort, known bool) []byte {
+	var buf bytes.Buffer
+	buf.Write(proxyProtocolV2Signature)
+
+	if !known {
+		// バージョン2・LOCALコマンド、アドレス情報なし
+		buf.Write([]byte{0x20, 0x00, 0x00, 0x00})
+		return buf.Bytes()
+	}
+
+	// バージョン2・PROXYコマンド
+	buf.WriteByte(0x21)
+	var addresses []byte
+	if src.Addr().Is4() {
+		buf.WriteByte(0x11) // TCP over IPv4
+		srcIP, dstIP := src.Addr().As4(), dst.Addr().As4()
+		addresses = append(addresses, srcIP[:]...)
+		addresses = append(addresses, dstIP[:]...)
+	} else {
+		buf.WriteByte(0x21) // TCP over IPv6
+		srcIP, dstIP := src.Addr().As16(), dst.Addr().As16()
+		addresses = append(addresses, srcIP[:]...)
+		addresses = append(addresses, dstIP[:]...)
+	}
+	addresses = binary.BigEndian.AppendUint16(addresses, src.Port())
+	addresses = binary.BigEndian.AppendUint16(addresses, dst.Port())
+
+	binary.Write(&buf, binary.BigEndian, uint16(len(addresses)))
+	buf.Write(addresses)
+	return buf.Bytes()
+}
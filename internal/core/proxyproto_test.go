@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// headerConn はPROXYプロトコルのヘッダを書き込まれる接続
+// RemoteAddr はローカルサーバのアドレスとして使われる
+type headerConn struct {
+	net.Conn
+	remote net.Addr
+	buffer bytes.Buffer
+}
+
+func (c *headerConn) RemoteAddr() net.Addr        { return c.remote }
+func (c *headerConn) Write(p []byte) (int, error) { return c.buffer.Write(p) }
+
+// concat はバイト列をつなげる
+func concat(parts ...[]byte) []byte {
+	var result []byte
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
+
+var (
+	ipv4Local = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 25565}
+	ipv6Local = &net.TCPAddr{IP: net.IPv6loopback, Port: 25565}
+)
+
+// 送信元と宛先のアドレスファミリーごとに、v1・v2のヘッダをバイト単位で確かめる
+func TestWriteProxyHeader(t *testing.T) {
+	v2Ports := []byte{0xc3, 0x50, 0x63, 0xdd} // 50000, 25565
+	tests := []struct {
+		name       string
+		remoteAddr string
+		local      net.Addr
+		v1         string
+		v2         []byte
+	}{
+		{
+			name:       "ipv4",
+			remoteAddr: "203.0.113.5:50000",
+			local:      ipv4Local,
+			v1:         "PROXY TCP4 203.0.113.5 127.0.0.1 50000 25565\r\n",
+			v2: concat(proxyProtocolV2Signature, []byte{0x21, 0x11, 0x00, 0x0c},
+				[]byte{203, 0, 113, 5}, []byte{127, 0, 0, 1}, v2Ports),
+		},
+		{
+			name:       "ipv6",
+			remoteAddr: "[2001:db8::5]:50000",
+			local:      ipv6Local,
+			v1:         "PROXY TCP6 2001:db8::5 ::1 50000 25565\r\n",
+			v2: concat(proxyProtocolV2Signature, []byte{0x21, 0x21, 0x00, 0x24},
+				[]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5},
+				[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, v2Ports),
+		},
+		{
+			// IPv4射影アドレスはIPv4として送る
+			name:       "ipv4-mapped",
+			remoteAddr: "[::ffff:203.0.113.5]:50000",
+			local:      ipv4Local,
+			v1:         "PROXY TCP4 203.0.113.5 127.0.0.1 50000 25565\r\n",
+			v2: concat(proxyProtocolV2Signature, []byte{0x21, 0x11, 0x00, 0x0c},
+				[]byte{203, 0, 113, 5}, []byte{127, 0, 0, 1}, v2Ports),
+		},
+		{
+			// ファミリーが異なる場合は両方をIPv6で送る
+			name:       "mixed",
+			remoteAddr: "203.0.113.5:50000",
+			local:      ipv6Local,
+			v1:         "PROXY TCP6 ::ffff:203.0.113.5 ::1 50000 25565\r\n",
+			v2: concat(proxyProtocolV2Signature, []byte{0x21, 0x21, 0x00, 0x24},
+				[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 203, 0, 113, 5},
+				[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, v2Ports),
+		},
+		{
+			// 古いリレーなどでプレイヤーのアドレスが分からない
+			name:       "unknown",
+			remoteAddr: "",
+			local:      ipv4Local,
+			v1:         "PROXY UNKNOWN\r\n",
+			v2:         concat(proxyProtocolV2Signature, []byte{0x20, 0x00, 0x00, 0x00}),
+		},
+		{
+			name:       "unparsable",
+			remoteAddr: "not-an-address",
+			local:      ipv4Local,
+			v1:         "PROXY UNKNOWN\r\n",
+			v2:         concat(proxyProtocolV2Signature, []byte{0x20, 0x00, 0x00, 0x00}),
+		},
+	}
+	for _, tt := range tests {
+		v1 := &headerConn{remote: tt.local}
+		if err := writeProxyHeader(v1, PROXY_PROTOCOL_V1, tt.remoteAddr); err != nil {
+			t.Fatalf("%s: v1: %v", tt.name, err)
+		}
+		if got := v1.buffer.String(); got != tt.v1 {
+			t.Errorf("%s: v1 header = %q, want %q", tt.name, got, tt.v1)
+		}
+
+		v2 := &headerConn{remote: tt.local}
+		if err := writeProxyHeader(v2, PROXY_PROTOCOL_V2, tt.remoteAddr); err != nil {
+			t.Fatalf("%s: v2: %v", tt.name, err)
+		}
+		if got := v2.buffer.Bytes(); !bytes.Equal(got, tt.v2) {
+			t.Errorf("%s: v2 header = % x, want % x", tt.name, got, tt.v2)
+		}
+	}
+}
+
+// 対応していないバージョンではヘッダを書き込まずにエラーにすること
+func TestWriteProxyHeaderUnsupportedVersion(t *testing.T) {
+	conn := &headerConn{remote: ipv4Local}
+	if err := writeProxyHeader(conn, "v3", "203.0.113.5:50000"); err == nil {
+		t.Fatal("unsupported version was accepted")
+	}
+	if conn.buffer.Len() != 0 {
+		t.Fatalf("header was written: %q", conn.buffer.Bytes())
+	}
+}
+
+// 設定値の表記ゆれを正規化し、不明な値はエラーにすること
+func TestParseProxyProtocol(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"off", ""},
+		{"false", ""},
+		{"None", ""},
+		{"1", PROXY_PROTOCOL_V1},
+		{" v1 ", PROXY_PROTOCOL_V1},
+		{"2", PROXY_PROTOCOL_V2},
+		{"V2", PROXY_PROTOCOL_V2},
+	}
+	for _, tt := range tests {
+		got, err := ParseProxyProtocol(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseProxyProtocol(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"v3", "on", "proxy"} {
+		if got, err := ParseProxyProtocol(value); err == nil {
+			t.Errorf("ParseProxyProtocol(%q) = %q, want an error", value, got)
+		}
+	}
+}
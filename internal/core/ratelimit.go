@@ -0,0 +1,267 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 新しい接続の受け入れ制限
+//
+// 0の項目は既定値を使い、負の値を指定するとその制限を外す。
+// IPアドレスごとの制限は、同じIPアドレスから多くの接続を開くブラウザ（Dynmapなど）や
+// CGNATで同じIPアドレスを共有するプレイヤーを妨げないように、指定した場合だけ使う
+type RateLimitConfig struct {
+	Rate            float64 // 全体で1秒あたりに受け入れる新しい接続の数
+	Burst           int     // 全体で一度に受け入れられる新しい接続の数
+	PerIPRate       float64 // 同じIPアドレスから1秒あたりに受け入れる新しい接続の数（0なら制限しない）
+	PerIPBurst      int     // 同じIPアドレスから一度に受け入れられる新しい接続の数（0なら既定値）
+	MaxStreams      int     // 同時に転送する接続の上限
+	MaxStreamsPerIP int     // 同じIPアドレスから同時に転送する接続の上限（0なら制限しない）
+	// AttackThreshold 件以上を10秒以内に拒否したら攻撃を受けているとみなす
+	AttackThreshold int
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Rate:            20,
+		Burst:           50,
+		MaxStreams:      256,
+		AttackThreshold: 20,
+	}
+}
+
+// PerIPRate だけを指定した場合のバースト
+const defaultPerIPBurst = 5
+
+func (r RateLimitConfig) withDefaults() RateLimitConfig {
+	def := DefaultRateLimitConfig()
+	if r.Rate == 0 {
+		r.Rate = def.Rate
+	}
+	if r.Burst == 0 {
+		r.Burst = def.Burst
+	}
+	if r.PerIPRate > 0 && r.PerIPBurst == 0 {
+		r.PerIPBurst = defaultPerIPBurst
+	}
+	if r.MaxStreams == 0 {
+		r.MaxStreams = def.MaxStreams
+	}
+	if r.AttackThreshold <= 0 {
+		r.AttackThreshold = def.AttackThreshold
+	}
+	return r
+}
+
+// 攻撃を受けているかを判定する期間
+const attackWindow = 10 * time.Second
+
+// 使われなくなったIPアドレスごとのバケットを捨てるまでの時間
+const ipBucketIdleTimeout = time.Minute
+
+// tokenBucket はトークンバケット方式で受け入れる量を制限する
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// 拒否した接続の数
+type RejectionStats struct {
+	RateLimited    uint64 // 全体の受け入れ制限を超えた
+	IPRateLimited  uint64 // 同じIPアドレスからの受け入れ制限を超えた
+	TooManyStreams uint64 // 同時接続数の上限を超えた
+	AccessDenied   uint64 // アクセス制限で拒否した
+	UnderAttack    bool   // 直近に多くの接続を拒否している
+}
+
+// Total は拒否した接続の合計を返す
+func (s RejectionStats) Total() uint64 {
+	return s.RateLimited + s.IPRateLimited + s.TooManyStreams + s.AccessDenied
+}
+
+// connLimiter は新しい接続の受け入れを制限し、拒否した数を数える
+type connLimiter struct {
+	config RateLimitConfig
+
+	mutex     sync.Mutex
+	global    *tokenBucket
+	perIP     map[string]*tokenBucket
+	lastSweep time.Time
+
+	rateLimited    atomic.Uint64
+	ipRateLimited  atomic.Uint64
+	tooManyStreams atomic.Uint64
+	accessDenied   atomic.Uint64
+
+	attackMutex   sync.Mutex
+	windowStart   time.Time
+	windowCount   int
+	previousCount int
+	underAttack   bool
+}
+
+func newConnLimiter(config RateLimitConfig) *connLimiter {
+	now := time.Now()
+	l := &connLimiter{
+		config:    config.withDefaults(),
+		perIP:     make(map[string]*tokenBucket),
+		lastSweep: now,
+	}
+	if l.config.Rate > 0 && l.config.Burst > 0 {
+		l.global = newTokenBucket(l.config.Rate, l.config.Burst, now)
+	}
+	return l
+}
+
+// allow は新しい接続を受け入れてよいかを確かめる
+// 拒否する場合はその理由を返す
+func (l *connLimiter) allow(remoteAddr string) (string, bool) {
+	now := time.Now()
+	host := remoteHost(remoteAddr)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) > ipBucketIdleTimeout {
+		for ip, bucket := range l.perIP {
+			if now.Sub(bucket.last) > ipBucketIdleTimeout {
+				delete(l.perIP, ip)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	// 1つのIPアドレスからの連続した接続で全体の枠を使い切られないように、先に確かめる
+	if l.config.PerIPRate > 0 && l.config.PerIPBurst > 0 && remoteAddr != "" {
+		bucket, exists := l.perIP[host]
+		if !exists {
+			bucket = newTokenBucket(l.config.PerIPRate, l.config.PerIPBurst, now)
+			l.perIP[host] = bucket
+		}
+		if !bucket.allow(now) {
+			l.ipRateLimited.Add(1)
+			l.recordRejection(now)
+			return "too many new connections from " + host, false
+		}
+	}
+
+	if l.global != nil && !l.global.allow(now) {
+		l.rateLimited.Add(1)
+		l.recordRejection(now)
+		return "too many new connections", false
+	}
+	return "", true
+}
+
+// checkStreams は同時接続数の上限を確かめる
+// streams は現在の接続数、fromIP は同じIPアドレスからの接続数
+func (l *connLimiter) checkStreams(streams, fromIP int, remoteAddr string) (string, bool) {
+	if l.config.MaxStreams > 0 && streams >= l.config.MaxStreams {
+		l.tooManyStreams.Add(1)
+		l.recordRejection(time.Now())
+		return fmt.Sprintf("too many connections (max %d)", l.config.MaxStreams), false
+	}
+	if l.config.MaxStreamsPerIP > 0 && remoteAddr != "" && fromIP >= l.config.MaxStreamsPerIP {
+		l.tooManyStreams.Add(1)
+		l.recordRejection(time.Now())
+		return fmt.Sprintf("too many connections from %s (max %d)", remoteHost(remoteAddr), l.config.MaxStreamsPerIP), false
+	}
+	return "", true
+}
+
+// denied はアクセス制限で拒否したことを記録する
+func (l *connLimiter) denied() {
+	l.accessDenied.Add(1)
+	l.recordRejection(time.Now())
+}
+
+// recordRejection は拒否した時刻を記録し、攻撃を受けているかを判定する
+func (l *connLimiter) recordRejection(now time.Time) {
+	l.attackMutex.Lock()
+	defer l.attackMutex.Unlock()
+
+	l.rotateWindow(now)
+	l.windowCount++
+	if !l.underAttack && l.windowCount+l.previousCount >= l.config.AttackThreshold {
+		l.underAttack = true
+		log.Printf("Many connections are being rejected. The server may be under attack")
+	}
+}
+
+// rotateWindow は判定期間を進める（attackMutexを保持して呼ぶ）
+func (l *connLimiter) rotateWindow(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if elapsed < attackWindow {
+		return
+	}
+	if elapsed < 2*attackWindow {
+		l.previousCount = l.windowCount
+	} else {
+		l.previousCount = 0
+	}
+	l.windowCount = 0
+	l.windowStart = now
+
+	if l.underAttack && l.previousCount == 0 {
+		l.underAttack = false
+		log.Printf("Connection rejections have calmed down")
+	}
+}
+
+func (l *connLimiter) stats() RejectionStats {
+	l.attackMutex.Lock()
+	l.rotateWindow(time.Now())
+	underAttack := l.underAttack
+	l.attackMutex.Unlock()
+
+	return RejectionStats{
+		RateLimited:    l.rateLimited.Load(),
+		IPRateLimited:  l.ipRateLimited.Load(),
+		TooManyStreams: l.tooManyStreams.Load(),
+		AccessDenied:   l.accessDenied.Load(),
+		UnderAttack:    underAttack,
+	}
+}
+
+// Rejections は拒否した接続の数と、攻撃を受けているかどうかを返す
+func (c *FRPClient) Rejections() RejectionStats {
+	return c.limiter.stats()
+}
+
+// checkStreamLimits は同時接続数の上限を確かめる（c.mutexを保持して呼ぶ）
+func (c *FRPClient) checkStreamLimits(remoteAddr string) (string, bool) {
+	fromIP := 0
+	if remoteAddr != "" {
+		host := remoteHost(remoteAddr)
+		for _, s := range c.localConns {
+			if s.remoteAddr != "" && remoteHost(s.remoteAddr) == host {
+				fromIP++
+			}
+		}
+	}
+	return c.limiter.checkStreams(len(c.localConns), fromIP, remoteAddr)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+)
+
+// 既定ではIPアドレスごとの制限は無く、同じIPアドレスからの多数の接続も全体の枠まで受け入れること
+func TestDefaultRateLimitHasNoPerIPLimits(t *testing.T) {
+	limiter := newConnLimiter(RateLimitConfig{})
+	burst := DefaultRateLimitConfig().Burst
+
+	for i := range burst {
+		if reason, ok := limiter.allow("203.0.113.5:50000"); !ok {
+			t.Fatalf("connection %d from one IP rejected: %s", i+1, reason)
+		}
+	}
+	if _, ok := limiter.checkStreams(burst, burst, "203.0.113.5:50000"); !ok {
+		t.Fatal("streams from one IP were capped by default")
+	}
+}
+
+// PerIPRate を指定した場合は同じIPアドレスからの接続をバーストの後で拒否すること
+func TestPerIPRateLimitOptIn(t *testing.T) {
+	limiter := newConnLimiter(RateLimitConfig{PerIPRate: 1})
+
+	for i := range defaultPerIPBurst {
+		if reason, ok := limiter.allow("203.0.113.5:50000"); !ok {
+			t.Fatalf("connection %d rejected within the burst: %s", i+1, reason)
+		}
+	}
+	if _, ok := limiter.allow("203.0.113.5:50001"); ok {
+		t.Fatal("connection beyond the per-IP burst was accepted")
+	}
+	if _, ok := limiter.allow("198.51.100.7:50000"); !ok {
+		t.Fatal("another IP was rejected by the per-IP limit")
+	}
+	if n := limiter.stats().IPRateLimited; n != 1 {
+		t.Fatalf("IPRateLimited = %d, want 1", n)
+	}
+}
+
+// UDPの新しいセッションにも同時接続数の上限を使うこと
+func TestUDPSessionLimit(t *testing.T) {
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_UDP,
+		LocalIP:      "127.0.0.1",
+		LocalPort:    listenUDPEcho(t),
+		RemotePort:   19132,
+	})
+	client := startClient(t, relay.Addr(), Options{RateLimit: RateLimitConfig{MaxStreams: 1}})
+	rc := relay.accept()
+
+	rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: "203.0.113.5:50000", Data: []byte("first")})
+	if reply := rc.next(); string(reply.Data) != "first" {
+		t.Fatalf("unexpected reply: %q", reply.Data)
+	}
+	rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: "198.51.100.7:50000", Data: []byte("second")})
+	rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: "203.0.113.5:50000", Data: []byte("third")})
+
+	// 上限を超えた送信元への応答は無く、既存のセッションはそのまま使える
+	if reply := rc.next(); string(reply.Data) != "third" {
+		t.Fatalf("unexpected reply: %q", reply.Data)
+	}
+	if n := client.Rejections().TooManyStreams; n != 1 {
+		t.Fatalf("TooManyStreams = %d, want 1", n)
+	}
+}
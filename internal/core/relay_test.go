@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// テストで応答を待つ時間の上限
+const testTimeout = 10 * time.Second
+
+// fakeRelay はテスト用のリレーサーバ
+// ログインにはJSONで応答し、以降はバイナリフレームでやり取りする
+type fakeRelay struct {
+	t         *testing.T
+	listener  net.Listener
+	tokenInfo TokenInfo
+	features  []string
+	conns     chan *relayConn
+}
+
+// relayConn はリレーが受け入れた1本の制御コネクション
+type relayConn struct {
+	t          *testing.T
+	conn       net.Conn
+	login      Message
+	codec      codec
+	writeMutex sync.Mutex
+	messages   chan Message
+}
+
+// newFakeRelay は listener でクライアントを待ち受けるリレーを起動する（nilなら127.0.0.1の空きポート）
+// ログイン応答では tokenInfo と features を返す
+func newFakeRelay(t *testing.T, listener net.Listener, tokenInfo TokenInfo, features ...string) *fakeRelay {
+	t.Helper()
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	r := &fakeRelay{
+		t:         t,
+		listener:  listener,
+		tokenInfo: tokenInfo,
+		features:  features,
+		conns:     make(chan *relayConn, 4),
+	}
+	go r.acceptLoop()
+	return r
+}
+
+func (r *fakeRelay) Addr() string {
+	return r.listener.Addr().String()
+}
+
+func (r *fakeRelay) acceptLoop() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go r.serve(conn)
+	}
+}
+
+func (r *fakeRelay) serve(conn net.Conn) {
+	jc := newJSONCodec(conn, conn)
+	var login Message
+	if err := jc.ReadMessage(&login); err != nil {
+		conn.Close()
+		return
+	}
+	tokenInfo := r.tokenInfo
+	response := Message{
+		Type:      "login_success",
+		Codec:     CODEC_BINARY,
+		Features:  r.features,
+		TokenInfo: &tokenInfo,
+	}
+	if err := jc.WriteMessage(&response); err != nil {
+		conn.Close()
+		return
+	}
+
+	rc := &relayConn{
+		t:        r.t,
+		conn:     conn,
+		login:    login,
+		codec:    newBinaryCodecAfterJSON(io.MultiReader(jc.buffered(), conn), conn),
+		messages: make(chan Message, 4096),
+	}
+	r.conns <- rc
+
+	defer close(rc.messages)
+	for {
+		var msg Message
+		if err := rc.codec.ReadMessage(&msg); err != nil {
+			return
+		}
+		rc.messages <- msg
+	}
+}
+
+// accept はクライアントがログインするまで待つ
+func (r *fakeRelay) accept() *relayConn {
+	r.t.Helper()
+	select {
+	case rc := <-r.conns:
+		r.t.Cleanup(func() { rc.conn.Close() })
+		return rc
+	case <-time.After(testTimeout):
+		r.t.Fatal("client did not log in")
+		return nil
+	}
+}
+
+// send はクライアントへメッセージを送る
+func (rc *relayConn) send(msg Message) {
+	rc.t.Helper()
+	rc.writeMutex.Lock()
+	defer rc.writeMutex.Unlock()
+	if err := rc.codec.WriteMessage(&msg); err != nil {
+		rc.t.Errorf("relay write: %v", err)
+	}
+}
+
+// next はクライアントから次のメッセージが届くまで待つ
+func (rc *relayConn) next() Message {
+	rc.t.Helper()
+	select {
+	case msg, ok := <-rc.messages:
+		if !ok {
+			rc.t.Fatal("control connection closed")
+		}
+		return msg
+	case <-time.After(testTimeout):
+		rc.t.Fatal("timed out waiting for a message from the client")
+		return Message{}
+	}
+}
+
+// startClient はリレーに接続するクライアントを起動し、テストの終わりに停止する
+func startClient(t *testing.T, relayAddr string, options Options) *FRPClient {
+	t.Helper()
+	client := NewFRPClientWithOptions(relayAddr, "test-token", options)
+	go client.Start(context.Background())
+	t.Cleanup(client.Stop)
+	return client
+}
+
+// listenLocal はテスト用のローカルサービスを起動し、そのポートを返す
+func listenLocal(t *testing.T, handle func(net.Conn)) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
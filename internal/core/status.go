@@ -0,0 +1,94 @@
+package core
+
+import "sync"
+
+// トンネルの状態
+//
+// FRPClientが状態を変えるたびに新しい値を作って通知するので、受け取った値はそのまま読んでよい
+type Status struct {
+	Running    bool          // Start()を実行中
+	State      ConnState     // 接続状態
+	PublicAddr string        // 公開中のアドレス（接続済みの場合）
+	Route      string        // 公開中の全プロキシの対応（1行に1つ）
+	Proxies    []ProxyConfig // 公開中のプロキシ
+	Kicked     bool          // サーバからキックされて終了した
+	LastError  string        // Start()がエラーで終了した場合の理由
+}
+
+// Connected はリレーに接続済みかどうかを返す
+func (s Status) Connected() bool {
+	return s.State.Kind == StateConnected
+}
+
+// statusHub は現在の状態を保持し、変わるたびに購読者へ通知する
+type statusHub struct {
+	mutex       sync.Mutex
+	status      Status
+	subscribers map[chan Status]struct{}
+}
+
+// get は現在の状態を返す
+func (h *statusHub) get() Status {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.status
+}
+
+// update は状態を書き換えて購読者へ通知する
+func (h *statusHub) update(change func(*Status)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	status := h.status
+	change(&status)
+	status.Proxies = append([]ProxyConfig(nil), status.Proxies...)
+	h.status = status
+
+	for ch := range h.subscribers {
+		// 読み終わっていない古い状態は捨てて、最新の状態だけを残す
+		select {
+		case ch <- status:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- status
+		}
+	}
+}
+
+func (h *statusHub) subscribe() (<-chan Status, func()) {
+	ch := make(chan Status, 1)
+
+	h.mutex.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan Status]struct{})
+	}
+	h.subscribers[ch] = struct{}{}
+	// 購読を始めた時点の状態をすぐに受け取れるようにする
+	ch <- h.status
+	h.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mutex.Lock()
+			delete(h.subscribers, ch)
+			close(ch)
+			h.mutex.Unlock()
+		})
+	}
+	return ch, cancel
+}
+
+// Status は現在のトンネルの状態を返す
+func (c *FRPClient) Status() Status {
+	return c.status.get()
+}
+
+// Subscribe は状態が変わるたびに新しい状態を受け取るチャンネルを返す
+// 受け取りが遅れた場合は途中の状態を飛ばして最新の状態だけが届く。不要になったらcancelを呼ぶ
+func (c *FRPClient) Subscribe() (<-chan Status, func()) {
+	return c.status.subscribe()
+}
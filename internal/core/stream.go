@@ -0,0 +1,282 @@
+package core
+
+import (
+	"QuickPort/internal/minecraft"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 1ストリームあたりのウィンドウサイズ（受信・送信とも同じ値から始める）
+// 受信バッファはこの値を超えないので、メモリ使用量はストリーム数に比例して頭打ちになる
+const streamWindowSize = 256 * 1024
+
+// フロー制御に対応していない古いリレーで、1ストリームが受信バッファに溜めてよい上限
+// 古いリレーは送る量を調整できないので、ウィンドウより大きく取り、超えたストリームだけを切断する
+const legacyStreamBufferSize = 4 * streamWindowSize
+
+// ローカル接続からの1回の読み込みサイズ
+const streamReadSize = 4096
+
+var errStreamClosed = errors.New("stream closed")
+
+// stream はリレー上の1本の接続（ConnID）とローカルサービスへの接続の組
+//
+// リレーから届いたデータは受信バッファに積むだけにして、ローカルへの書き込みは
+// ストリームごとのgoroutineで行う。遅いプレイヤーの接続が他のプレイヤーを止めないようにするため
+type stream struct {
+	id         string
+	conn       net.Conn
+	proxyName  string
+	remoteAddr string // プレイヤーのアドレス（古いリレーでは空）
+	opened     time.Time
+	offline    bool          // ローカルサーバの代わりにオフライン応答をしている
+	bytesIn    atomic.Uint64 // プレイヤーから受け取ったバイト数
+	bytesOut   atomic.Uint64 // プレイヤーへ送ったバイト数
+
+	inspector *minecraft.Inspector // 先頭のパケットの解析（解析しない場合・解析後はnil）
+	minecraft *minecraft.ConnInfo  // 解析できたMinecraftの接続情報（mutexで保護）
+
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	pending    [][]byte // ローカルへ書き込み待ちのデータ
+	buffered   int      // 受信バッファ上のバイト数（書き込み中のものを含む）
+	finished   bool     // リレー側から切断された（残りを書き終えたら閉じる）
+	closed     bool
+	notified   bool // リレーへcloseを送った
+	sendWindow int  // リレーへ送ってよい残りバイト数（フロー制御が有効な場合のみ使用）
+}
+
+func newStream(id string, conn net.Conn) *stream {
+	s := &stream{
+		id:         id,
+		conn:       conn,
+		opened:     time.Now(),
+		sendWindow: streamWindowSize,
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// enqueue はリレーから届いたデータを受信バッファに積む
+// フロー制御が有効ならウィンドウを、古いリレーでは legacyStreamBufferSize を超えたデータはエラーにする。
+// 待つとリレーからの読み込みが止まって他のストリームも止まるので、待たずに呼び出し元で切断する
+func (s *stream) enqueue(data []byte, flowControl bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed || s.finished {
+		return errStreamClosed
+	}
+	if flowControl && s.buffered+len(data) > streamWindowSize {
+		return fmt.Errorf("receive window exceeded (%d + %d > %d bytes)", s.buffered, len(data), streamWindowSize)
+	}
+	if !flowControl && s.buffered+len(data) > legacyStreamBufferSize {
+		return fmt.Errorf("receive buffer full (%d + %d > %d bytes)", s.buffered, len(data), legacyStreamBufferSize)
+	}
+
+	s.pending = append(s.pending, data)
+	s.buffered += len(data)
+	s.cond.Broadcast()
+	return nil
+}
+
+// next はローカルへ書き込む次のデータを待つ
+// ストリームが閉じられたか、切断後に書き込むデータが無くなった場合はfalseを返す
+func (s *stream) next() ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.pending) == 0 && !s.closed && !s.finished {
+		s.cond.Wait()
+	}
+	if s.closed || len(s.pending) == 0 {
+		return nil, false
+	}
+
+	data := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	return data, true
+}
+
+// consumed はローカルへの書き込みが終わった分を受信バッファから外す
+func (s *stream) consumed(n int) {
+	s.mutex.Lock()
+	s.buffered -= n
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+// waitSendWindow はリレーへ送れる量が空くまで待ち、送信可能なバイト数を返す
+func (s *stream) waitSendWindow() (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for s.sendWindow <= 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return 0, false
+	}
+	return s.sendWindow, true
+}
+
+func (s *stream) spendSendWindow(n int) {
+	s.mutex.Lock()
+	s.sendWindow -= n
+	s.mutex.Unlock()
+}
+
+// addSendWindow はリレーから受け取ったWINDOW_UPDATEの分だけ送信可能量を増やす
+func (s *stream) addSendWindow(n int) {
+	s.mutex.Lock()
+	s.sendWindow += n
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+// finish はリレー側の切断を記録する
+// 受信バッファに残っているデータはローカルへ書き終えてから閉じる
+func (s *stream) finish() {
+	s.mutex.Lock()
+	s.finished = true
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+// claimCloseNotice はリレーへのclose送信を1回だけにするための印を付ける
+// 初めて呼ばれた場合のみtrueを返す
+func (s *stream) claimCloseNotice() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.notified {
+		return false
+	}
+	s.notified = true
+	return true
+}
+
+// close はストリームを直ちに閉じる
+func (s *stream) close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.pending = nil
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+
+	s.conn.Close()
+}
+
+// writeToLocal は受信バッファのデータを順にローカルサービスへ書き込む
+func (c *FRPClient) writeToLocal(s *stream) {
+	defer s.close()
+
+	for {
+		data, ok := s.next()
+		if !ok {
+			return
+		}
+
+		c.waitDownstream(len(data))
+		if _, err := s.conn.Write(data); err != nil {
+			log.Printf("Local connection write error for %s: %v", s.id, err)
+			return
+		}
+		s.consumed(len(data))
+
+		// 書き込んだ分だけリレーに受信ウィンドウを返す
+		if c.flowControl.Load() {
+			if err := c.sendWindowUpdate(s.id, len(data)); err != nil {
+				log.Printf("Failed to send window update for %s: %v", s.id, err)
+				return
+			}
+		}
+	}
+}
+
+// readFromLocal はローカルサービスからのデータをリレーへ転送する
+// フロー制御が有効な場合は、リレーから許可された量までしか送らない
+func (c *FRPClient) readFromLocal(s *stream) error {
+	buffer := make([]byte, streamReadSize)
+	for {
+		size := len(buffer)
+		if c.flowControl.Load() {
+			window, ok := s.waitSendWindow()
+			if !ok {
+				return nil
+			}
+			size = min(size, window)
+		}
+
+		n, err := s.conn.Read(buffer[:size])
+		if err != nil {
+			// オフライン応答などの仮想的な接続はio.ErrClosedPipeで閉じられる
+			if err == io.EOF || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+				return nil
+			}
+			return err
+		}
+
+		if c.flowControl.Load() {
+			s.spendSendWindow(n)
+		}
+		s.bytesOut.Add(uint64(n))
+		c.countOut(n)
+		if err := c.sendDataMessage(s.id, buffer[:n]); err != nil {
+			return fmt.Errorf("failed to forward data: %v", err)
+		}
+	}
+}
+
+func (c *FRPClient) handleWindowUpdate(msg *Message) {
+	c.mutex.RLock()
+	s, exists := c.localConns[msg.ConnID]
+	c.mutex.RUnlock()
+
+	if exists && msg.Window > 0 {
+		s.addSendWindow(msg.Window)
+	}
+}
+
+// notifyClose はストリームの切断をリレーへ伝える（既に伝えていれば何もしない）
+func (c *FRPClient) notifyClose(s *stream) error {
+	if !s.claimCloseNotice() {
+		return nil
+	}
+	return c.sendCloseMessage(s.id)
+}
+
+func (c *FRPClient) sendWindowUpdate(connID string, n int) error {
+	msg := Message{
+		Type:   MSG_TYPE_WINDOW_UPDATE,
+		ConnID: connID,
+		Window: n,
+	}
+
+	return c.send(&msg)
+}
+
+// closeAllStreams は制御コネクションが切れたときに全ストリームを閉じる
+// ConnIDは接続ごとに振り直されるので、再接続後に引き継ぐことはできない
+func (c *FRPClient) closeAllStreams() {
+	c.mutex.Lock()
+	streams := c.localConns
+	c.localConns = make(map[string]*stream)
+	c.mutex.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+	c.reportConnections()
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// フロー制御が有効な場合、ウィンドウを超えたデータはエラーになること
+func TestEnqueueWindowExceeded(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	s := newStream("a", local)
+	defer s.close()
+
+	if err := s.enqueue(make([]byte, streamWindowSize), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.enqueue([]byte("x"), true); err == nil {
+		t.Fatal("enqueue beyond the window succeeded with flow control")
+	}
+}
+
+// 古いリレーではウィンドウを超えても受け入れ、受信バッファの上限を超えたら待たずにエラーにすること
+func TestEnqueueLegacyBufferFull(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	s := newStream("a", local)
+	defer s.close()
+
+	if err := s.enqueue(make([]byte, streamWindowSize+1), false); err != nil {
+		t.Fatalf("enqueue beyond the window failed without flow control: %v", err)
+	}
+	if err := s.enqueue(make([]byte, legacyStreamBufferSize-streamWindowSize-1), false); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.enqueue([]byte("x"), false)
+	}()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("enqueue beyond the legacy buffer succeeded")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("enqueue blocked on a full buffer")
+	}
+}
+
+// 古いリレーで、ローカルが読まないストリームがあっても他のストリームのデータは遅れずに届くこと
+func TestStalledStreamDoesNotBlockOthers(t *testing.T) {
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	// 受け取ったまま読まないローカルサービス
+	stalledPort := listenLocal(t, func(conn net.Conn) {
+		defer conn.Close()
+		<-stop
+	})
+	received := make(chan []byte, 1)
+	fastPort := listenLocal(t, func(conn net.Conn) {
+		defer conn.Close()
+		buffer := make([]byte, 64)
+		n, _ := conn.Read(buffer)
+		received <- buffer[:n]
+	})
+
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_TCP,
+		Proxies: []ProxyConfig{
+			{Name: "stalled", Type: PROXY_TYPE_TCP, LocalIP: "127.0.0.1", LocalPort: stalledPort, RemotePort: 40000},
+			{Name: "fast", Type: PROXY_TYPE_TCP, LocalIP: "127.0.0.1", LocalPort: fastPort, RemotePort: 40001},
+		},
+	})
+	startClient(t, relay.Addr(), Options{})
+	rc := relay.accept()
+
+	rc.send(Message{Type: MSG_TYPE_NEW_CONN, ProxyName: "stalled", ConnID: "stalled", RemoteAddr: "203.0.113.5:50000"})
+	rc.send(Message{Type: MSG_TYPE_NEW_CONN, ProxyName: "fast", ConnID: "fast", RemoteAddr: "203.0.113.6:50000"})
+	// ストリームが登録されるまで少し待つ
+	time.Sleep(50 * time.Millisecond)
+
+	// 受信バッファとソケットのバッファを埋めてから、別のストリームへ送る
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		chunk := make([]byte, 32*1024)
+		for range (32 << 20) / len(chunk) {
+			rc.send(Message{Type: MSG_TYPE_DATA, ConnID: "stalled", Data: chunk})
+		}
+		rc.send(Message{Type: MSG_TYPE_DATA, ConnID: "fast", Data: []byte("hello")})
+	}()
+
+	select {
+	case data := <-received:
+		if string(data) != "hello" {
+			t.Fatalf("fast stream received %q", data)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("data for another stream was held up by the stalled stream")
+	}
+	waitClose(t, rc, "stalled")
+	<-sent
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 制御コネクションのTLS設定
+type TLSConfig struct {
+	Enabled    bool   // TLSで接続するか
+	ServerName string // 証明書の検証に使うホスト名（空の場合は接続先アドレスのホスト部）
+	// サーバ公開鍵(SubjectPublicKeyInfo)のSHA-256ハッシュをbase64で指定する
+	// 指定した場合はシステムのルート証明書ではなく、このハッシュとの一致で検証する（自己署名証明書向け）
+	PinnedSPKI string
+}
+
+// TLSVerificationError はサーバ証明書の検証に失敗したことを表す
+// 通信経路の問題と区別して画面に表示するために使う
+type TLSVerificationError struct {
+	Err error
+}
+
+func (e *TLSVerificationError) Error() string {
+	return fmt.Sprintf("サーバ証明書の検証に失敗しました: %v", e.Err)
+}
+
+func (e *TLSVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// SPKIHash は証明書の公開鍵からピン留め用のハッシュを計算する
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// clientConfig は接続先アドレスに対するtls.Configを組み立てる
+func (t TLSConfig) clientConfig(serverAddr string) (*tls.Config, error) {
+	serverName := t.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(serverAddr)
+		if err != nil {
+			return nil, err
+		}
+		serverName = host
+	}
+
+	conf := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if t.PinnedSPKI == "" {
+		// システムのルート証明書で検証する
+		return conf, nil
+	}
+
+	pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(t.PinnedSPKI, "sha256/"))
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid pinned SPKI hash: %q", t.PinnedSPKI)
+	}
+
+	// 証明書チェーンの検証は行わず、公開鍵のハッシュだけを確認する
+	// ピン留めした証明書は公開されているので、チェーンの途中に含めただけで通らないようにリーフ証明書だけを見る
+	conf.InsecureSkipVerify = true
+	conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return &TLSVerificationError{Err: errors.New("サーバが証明書を提示しませんでした")}
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return &TLSVerificationError{Err: err}
+		}
+		sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+		if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+			return &TLSVerificationError{
+				Err: fmt.Errorf("公開鍵のハッシュがピン留めされた値と一致しません (サーバ: %s)", SPKIHash(leaf)),
+			}
+		}
+		return nil
+	}
+	return conf, nil
+}
+
+// dialTLS はTLSで接続し、証明書の検証エラーをTLSVerificationErrorに変換する
+func dialTLS(ctx context.Context, serverAddr string, t TLSConfig) (net.Conn, error) {
+	conf, err := t.clientConfig(serverAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := tls.Dialer{Config: conf}
+	conn, err := dialer.DialContext(ctx, "tcp", serverAddr)
+	if err != nil {
+		var verifyErr *TLSVerificationError
+		if errors.As(err, &verifyErr) {
+			return nil, err
+		}
+		if isCertificateError(err) {
+			return nil, &TLSVerificationError{Err: err}
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
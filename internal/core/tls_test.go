@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSignedCert はlocalhost用の自己署名証明書を作る
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+// listenTLS は証明書を提示するTLSの待ち受けを作る
+func listenTLS(t *testing.T, cert tls.Certificate) net.Listener {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+// dialTestTLS はハンドシェイクだけに応じるサーバへ接続した結果を返す
+func dialTestTLS(t *testing.T, cert tls.Certificate, config TLSConfig) error {
+	t.Helper()
+	listener := listenTLS(t, cert)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	conn, err := dialTLS(ctx, listener.Addr().String(), config)
+	if err == nil {
+		conn.Close()
+	}
+	return err
+}
+
+// 自己署名証明書のリレーに、公開鍵のピン留めで接続してログインできること
+func TestTLSRelayWithPinnedSPKI(t *testing.T) {
+	cert, leaf := selfSignedCert(t)
+	relay := newFakeRelay(t, listenTLS(t, cert), TokenInfo{ProtocolType: PROXY_TYPE_TCP, RemotePort: 40000})
+
+	startClient(t, relay.Addr(), Options{
+		TLS: TLSConfig{Enabled: true, PinnedSPKI: SPKIHash(leaf)},
+	})
+	rc := relay.accept()
+	if rc.login.Token != "test-token" {
+		t.Fatalf("login token = %q", rc.login.Token)
+	}
+}
+
+// ピン留めが無い場合、自己署名証明書はシステムのルート証明書で検証できずに失敗すること
+func TestTLSRejectsUntrustedCertificate(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+
+	err := dialTestTLS(t, cert, TLSConfig{Enabled: true})
+	var verifyErr *TLSVerificationError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("err = %v, want TLSVerificationError", err)
+	}
+}
+
+// 公開鍵がピン留めした値と異なる証明書は拒否すること
+func TestTLSRejectsPinMismatch(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	_, other := selfSignedCert(t)
+
+	err := dialTestTLS(t, cert, TLSConfig{Enabled: true, PinnedSPKI: SPKIHash(other)})
+	var verifyErr *TLSVerificationError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("err = %v, want TLSVerificationError", err)
+	}
+}
+
+// 別の証明書の後ろにピン留めした証明書を付けて提示されても拒否すること
+func TestTLSRejectsPinnedCertAfterOtherLeaf(t *testing.T) {
+	attacker, _ := selfSignedCert(t)
+	pinned, pinnedLeaf := selfSignedCert(t)
+	attacker.Certificate = append(attacker.Certificate, pinned.Certificate[0])
+	attacker.Leaf = nil
+
+	err := dialTestTLS(t, attacker, TLSConfig{Enabled: true, PinnedSPKI: SPKIHash(pinnedLeaf)})
+	var verifyErr *TLSVerificationError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("err = %v, want TLSVerificationError", err)
+	}
+}
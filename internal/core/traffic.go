@@ -0,0 +1,171 @@
+package core
+
+import (
+	"QuickPort/internal/traffic"
+	"log"
+	"sync"
+	"time"
+)
+
+// 通信量を記録ファイルへ書き出す間隔
+const trafficFlushInterval = time.Minute
+
+// shaper はトークンバケット方式で1秒あたりに通すバイト数を制限する
+// 足りない分は前借りして待つので、一度に大きなデータを渡しても平均は上限に収まる
+type shaper struct {
+	mutex  sync.Mutex
+	rate   float64 // 1秒あたりのバイト数
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newShaper(bytesPerSecond int64) *shaper {
+	rate := float64(bytesPerSecond)
+	// 100ミリ秒分までは溜めておけるようにして、細かい待ちを減らす
+	burst := max(rate/10, streamReadSize)
+	return &shaper{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait はnバイト通してよくなるまで待つ
+func (s *shaper) wait(n int) {
+	s.mutex.Lock()
+	now := time.Now()
+	s.tokens = min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.rate)
+	s.last = now
+	s.tokens -= float64(n)
+	deficit := -s.tokens
+	s.mutex.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / s.rate * float64(time.Second)))
+	}
+}
+
+// setupShaping はトークンの帯域制限（ローカル設定があればそちら）に合わせて転送速度を制限する
+func (c *FRPClient) setupShaping() {
+	limit := c.options.BandwidthLimit
+	if limit == "" && c.tokenInfo != nil {
+		limit = c.tokenInfo.BandwidthLimit
+	}
+
+	bytesPerSecond, err := traffic.ParseBandwidth(limit)
+	if err != nil {
+		log.Printf("Ignoring bandwidth limit: %v", err)
+		bytesPerSecond = 0
+	}
+	c.bandwidthLimit.Store(bytesPerSecond)
+
+	if !c.options.ShapeBandwidth || bytesPerSecond <= 0 {
+		c.upShaper.Store(nil)
+		c.downShaper.Store(nil)
+		return
+	}
+
+	log.Printf("Shaping traffic to %s/s in each direction", traffic.FormatBytes(uint64(bytesPerSecond)))
+	c.upShaper.Store(newShaper(bytesPerSecond))
+	c.downShaper.Store(newShaper(bytesPerSecond))
+}
+
+// countIn はプレイヤーからローカルサーバへ転送したバイト数を数える
+func (c *FRPClient) countIn(n int) {
+	c.bytesIn.Add(uint64(n))
+}
+
+// countOut はローカルサーバからプレイヤーへ転送したバイト数を数え、必要なら速度を制限する
+func (c *FRPClient) countOut(n int) {
+	c.bytesOut.Add(uint64(n))
+	if up := c.upShaper.Load(); up != nil {
+		up.wait(n)
+	}
+}
+
+// waitDownstream はリレーから受け取ったデータをローカルへ書き込む前に速度を制限する
+// フロー制御が無い場合に待つと受信ウィンドウを超えてしまうので、その場合は制限しない
+func (c *FRPClient) waitDownstream(n int) {
+	if !c.flowControl.Load() {
+		return
+	}
+	if down := c.downShaper.Load(); down != nil {
+		down.wait(n)
+	}
+}
+
+// 通信量の集計
+type TrafficStats struct {
+	BytesIn        uint64      // 起動してからプレイヤーから受け取ったバイト数
+	BytesOut       uint64      // 起動してからプレイヤーへ送ったバイト数
+	Today          traffic.Day // 今日の通信量（記録ファイルが無い場合は起動してからの分）
+	Month          traffic.Day // 今月の通信量（記録ファイルが無い場合は起動してからの分）
+	BandwidthLimit int64       // プランの帯域（1秒あたりのバイト数、0なら無制限）
+	Shaping        bool        // 転送速度を制限しているか
+}
+
+// Traffic は通信量の集計を返す
+func (c *FRPClient) Traffic() TrafficStats {
+	stats := TrafficStats{
+		BytesIn:        c.bytesIn.Load(),
+		BytesOut:       c.bytesOut.Load(),
+		BandwidthLimit: c.bandwidthLimit.Load(),
+		Shaping:        c.upShaper.Load() != nil,
+	}
+
+	if c.options.TrafficLog == nil {
+		stats.Today = traffic.Day{In: stats.BytesIn, Out: stats.BytesOut}
+		stats.Month = stats.Today
+		return stats
+	}
+
+	// まだ記録ファイルに書き出していない分を足す
+	c.trafficMutex.Lock()
+	pendingIn := stats.BytesIn - c.flushedIn
+	pendingOut := stats.BytesOut - c.flushedOut
+	c.trafficMutex.Unlock()
+
+	now := time.Now()
+	stats.Today = c.options.TrafficLog.Day(now)
+	stats.Today.In += pendingIn
+	stats.Today.Out += pendingOut
+	stats.Month = c.options.TrafficLog.Month(now)
+	stats.Month.In += pendingIn
+	stats.Month.Out += pendingOut
+	return stats
+}
+
+// flushTraffic は前回からの通信量を記録ファイルの今日の分に加える
+func (c *FRPClient) flushTraffic() {
+	if c.options.TrafficLog == nil {
+		return
+	}
+
+	c.trafficMutex.Lock()
+	defer c.trafficMutex.Unlock()
+
+	in, out := c.bytesIn.Load(), c.bytesOut.Load()
+	if err := c.options.TrafficLog.Add(time.Now(), in-c.flushedIn, out-c.flushedOut); err != nil {
+		log.Printf("Failed to save traffic log: %v", err)
+		return
+	}
+	c.flushedIn, c.flushedOut = in, out
+}
+
+// runTrafficFlush はstopが閉じられるまで定期的に通信量を書き出す
+// 最後の分はStart()の終了時に書き出す
+func (c *FRPClient) runTrafficFlush(stop <-chan struct{}) {
+	ticker := time.NewTicker(trafficFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.flushTraffic()
+		}
+	}
+}
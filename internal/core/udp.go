@@ -0,0 +1,213 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// プロキシの種類
+const (
+	PROXY_TYPE_TCP = "tcp"
+	PROXY_TYPE_UDP = "udp"
+)
+
+// UDPセッションを閉じるまでの無通信時間の既定値
+const defaultUDPIdleTimeout = 60 * time.Second
+
+// UDPデータグラムの最大長
+const maxDatagramSize = 64 * 1024
+
+// udpSession は送信元アドレスごとのローカルサービスへのUDP接続
+// Bedrock版やボイスチャットMODは送信元アドレスで相手を区別するので、プレイヤーごとに別のソケットを使う
+type udpSession struct {
+	proxyName  string
+	remoteAddr string
+	conn       net.Conn
+	lastActive time.Time // udpSessionsのロック下で更新する
+}
+
+func udpSessionKey(proxyName, remoteAddr string) string {
+	return proxyName + "|" + remoteAddr
+}
+
+func (c *FRPClient) handleUDPData(msg *Message) {
+	proxyConfig, ok := c.findProxy(msg.ProxyName)
+	if !ok || proxyConfig.Type != PROXY_TYPE_UDP {
+		log.Printf("Unknown UDP proxy: %s", msg.ProxyName)
+		return
+	}
+	if msg.RemoteAddr == "" {
+		return
+	}
+
+	session, err := c.getUDPSession(proxyConfig, msg.RemoteAddr)
+	if err != nil {
+		log.Printf("Failed to open UDP session for %s: %v", msg.RemoteAddr, err)
+		return
+	}
+
+	c.countIn(len(msg.Data))
+	// UDPは届かなくても再送されるので、書き込みに失敗しても接続は維持する
+	if _, err := session.conn.Write(msg.Data); err != nil {
+		log.Printf("UDP write error for %s: %v", msg.RemoteAddr, err)
+	}
+}
+
+// getUDPSession は送信元アドレスに対応するセッションを返す。無ければローカルサービスへ接続して作る
+func (c *FRPClient) getUDPSession(proxyConfig ProxyConfig, remoteAddr string) (*udpSession, error) {
+	key := udpSessionKey(proxyConfig.Name, remoteAddr)
+
+	c.udpMutex.Lock()
+	defer c.udpMutex.Unlock()
+
+	if session, exists := c.udpSessions[key]; exists {
+		session.lastActive = time.Now()
+		return session, nil
+	}
+
+	// 新しい送信元はTCPの接続と同じくアクセス制限と受け入れ制限を確かめてからローカルサービスへ接続する
+	if reason, ok := c.accessRules().CheckAddr(remoteAddr); !ok {
+		c.limiter.denied()
+		return nil, fmt.Errorf("rejected: %s", reason)
+	}
+	if reason, ok := c.limiter.allow(remoteAddr); !ok {
+		return nil, fmt.Errorf("rejected: %s", reason)
+	}
+	if reason, ok := c.checkUDPSessionLimits(remoteAddr); !ok {
+		return nil, fmt.Errorf("rejected: %s", reason)
+	}
+
+	localAddr := net.JoinHostPort(proxyConfig.LocalIP, strconv.Itoa(proxyConfig.LocalPort))
+	conn, err := net.Dial("udp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &udpSession{
+		proxyName:  proxyConfig.Name,
+		remoteAddr: remoteAddr,
+		conn:       conn,
+		lastActive: time.Now(),
+	}
+	c.udpSessions[key] = session
+	log.Printf("New UDP session %s: %s -> %s", remoteAddr, proxyConfig.Name, localAddr)
+
+	go c.forwardUDPFromLocal(session)
+	return session, nil
+}
+
+// forwardUDPFromLocal はローカルサービスからの応答を送信元アドレス付きでリレーへ返す
+func (c *FRPClient) forwardUDPFromLocal(session *udpSession) {
+	defer c.removeUDPSession(session)
+
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, err := session.conn.Read(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("UDP read error for %s: %v", session.remoteAddr, err)
+			}
+			return
+		}
+
+		c.udpMutex.Lock()
+		session.lastActive = time.Now()
+		c.udpMutex.Unlock()
+		c.countOut(n)
+
+		msg := Message{
+			Type:       MSG_TYPE_UDP,
+			ProxyName:  session.proxyName,
+			RemoteAddr: session.remoteAddr,
+			Data:       buffer[:n],
+		}
+		if err := c.send(&msg); err != nil {
+			log.Printf("Failed to forward UDP data for %s: %v", session.remoteAddr, err)
+			return
+		}
+	}
+}
+
+// checkUDPSessionLimits はUDPセッション数の上限を確かめる（udpMutexを保持して呼ぶ）
+func (c *FRPClient) checkUDPSessionLimits(remoteAddr string) (string, bool) {
+	host := remoteHost(remoteAddr)
+	fromIP := 0
+	for _, session := range c.udpSessions {
+		if remoteHost(session.remoteAddr) == host {
+			fromIP++
+		}
+	}
+	return c.limiter.checkStreams(len(c.udpSessions), fromIP, remoteAddr)
+}
+
+func (c *FRPClient) removeUDPSession(session *udpSession) {
+	session.conn.Close()
+
+	key := udpSessionKey(session.proxyName, session.remoteAddr)
+	c.udpMutex.Lock()
+	if c.udpSessions[key] == session {
+		delete(c.udpSessions, key)
+	}
+	c.udpMutex.Unlock()
+}
+
+// expireUDPSessions は一定時間通信の無いセッションを閉じる
+func (c *FRPClient) expireUDPSessions(now time.Time) {
+	idleTimeout := c.options.UDPIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultUDPIdleTimeout
+	}
+
+	var expired []*udpSession
+	c.udpMutex.Lock()
+	for key, session := range c.udpSessions {
+		if now.Sub(session.lastActive) > idleTimeout {
+			expired = append(expired, session)
+			delete(c.udpSessions, key)
+		}
+	}
+	c.udpMutex.Unlock()
+
+	for _, session := range expired {
+		log.Printf("UDP session %s expired", session.remoteAddr)
+		session.conn.Close()
+	}
+}
+
+// runUDPExpiry はstopが閉じられるまで定期的に期限切れのセッションを掃除する
+func (c *FRPClient) runUDPExpiry(stop <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			c.expireUDPSessions(now)
+		}
+	}
+}
+
+// closeAllUDPSessions は制御コネクションが切れたときに全セッションを閉じる
+func (c *FRPClient) closeAllUDPSessions() {
+	c.udpMutex.Lock()
+	sessions := c.udpSessions
+	c.udpSessions = make(map[string]*udpSession)
+	c.udpMutex.Unlock()
+
+	for _, session := range sessions {
+		session.conn.Close()
+	}
+}
+
+// UDPSessionCount は現在のUDPセッション数を返す
+func (c *FRPClient) UDPSessionCount() int {
+	c.udpMutex.Lock()
+	defer c.udpMutex.Unlock()
+	return len(c.udpSessions)
+}
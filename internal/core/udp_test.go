@@ -0,0 +1,108 @@
+package core
+
+import (
+	"QuickPort/internal/acl"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenUDPEcho は受け取ったデータグラムをそのまま返すローカルサービスを起動し、そのポートを返す
+func listenUDPEcho(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buffer := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buffer[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// リレー経由のデータグラムがローカルのエコーサーバから送信元ごとに返ってくること
+func TestUDPEchoThroughRelay(t *testing.T) {
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_UDP,
+		LocalIP:      "127.0.0.1",
+		LocalPort:    listenUDPEcho(t),
+		RemotePort:   19132,
+	})
+	client := startClient(t, relay.Addr(), Options{UDPIdleTimeout: time.Minute})
+	rc := relay.accept()
+
+	players := []string{"203.0.113.5:50000", "203.0.113.6:50001"}
+	for round := range 3 {
+		for _, player := range players {
+			payload := fmt.Sprintf("%s #%d", player, round)
+			rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: player, Data: []byte(payload)})
+
+			reply := rc.next()
+			if reply.Type != MSG_TYPE_UDP || reply.ProxyName != PROXY_TYPE_UDP {
+				t.Fatalf("unexpected reply: %+v", reply)
+			}
+			if reply.RemoteAddr != player || string(reply.Data) != payload {
+				t.Fatalf("reply to %s = %q, want %q for %s", reply.RemoteAddr, reply.Data, payload, player)
+			}
+		}
+	}
+
+	// 送信元アドレスごとにセッションを作り、同じ送信元では使い回す
+	if n := client.UDPSessionCount(); n != len(players) {
+		t.Fatalf("UDPSessionCount = %d, want %d", n, len(players))
+	}
+
+	// 無通信のセッションは期限が来たら閉じる
+	client.expireUDPSessions(time.Now().Add(2 * time.Minute))
+	if n := client.UDPSessionCount(); n != 0 {
+		t.Fatalf("UDPSessionCount after expiry = %d, want 0", n)
+	}
+}
+
+// IPアドレスの拒否条件に当てはまる送信元のデータグラムはローカルサービスへ届けないこと
+func TestUDPDeniedByIPRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), acl.FileName)
+	if err := os.WriteFile(path, []byte("deny ip 203.0.113.0/24\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accessList, err := acl.NewList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_UDP,
+		LocalIP:      "127.0.0.1",
+		LocalPort:    listenUDPEcho(t),
+		RemotePort:   19132,
+	})
+	client := startClient(t, relay.Addr(), Options{AccessList: accessList})
+	rc := relay.accept()
+
+	rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: "203.0.113.5:50000", Data: []byte("denied")})
+	rc.send(Message{Type: MSG_TYPE_UDP, ProxyName: PROXY_TYPE_UDP, RemoteAddr: "198.51.100.7:50000", Data: []byte("allowed")})
+
+	// 拒否した送信元への応答は無く、最初に返ってくるのは許可した送信元への応答
+	reply := rc.next()
+	if reply.RemoteAddr != "198.51.100.7:50000" || string(reply.Data) != "allowed" {
+		t.Fatalf("unexpected reply to %s: %q", reply.RemoteAddr, reply.Data)
+	}
+	if n := client.UDPSessionCount(); n != 1 {
+		t.Fatalf("UDPSessionCount = %d, want 1", n)
+	}
+	if denied := client.Rejections().AccessDenied; denied != 1 {
+		t.Fatalf("AccessDenied = %d, want 1", denied)
+	}
+}
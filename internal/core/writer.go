@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+)
+
+// 送信キューの長さ（ストリーム数によらず固定）
+// 各ストリームは自分のフレームが書き込まれるまで次を積まないので、キューに並ぶのは
+// 1ストリームにつき1フレームまで。送信中のストリームがこれより多い場合は
+// 空きができるまで Send が待つだけで、順序や結果は変わらない
+const sendQueueSize = 256
+
+var errWriterClosed = errors.New("control connection writer closed")
+
+// 送信待ちのメッセージ
+type outbound struct {
+	msg    *Message
+	result chan error
+}
+
+// connWriter は制御コネクションへの書き込みを1つのgoroutineに集約する
+//
+// Send は書き込みが終わるまで戻らないため、同じConnIDのフレームは
+// 呼び出し順に並び、異なるプレイヤーのフレームが混ざることもない
+type connWriter struct {
+	conn     net.Conn
+	codec    codec
+	queue    chan outbound
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newConnWriter(conn net.Conn, c codec) *connWriter {
+	w := &connWriter{
+		conn:  conn,
+		codec: c,
+		queue: make(chan outbound, sendQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *connWriter) run() {
+	defer close(w.done)
+
+	for {
+		select {
+		case req := <-w.queue:
+			err := w.codec.WriteMessage(req.msg)
+			req.result <- err
+			if err != nil {
+				// 書き込みに失敗したコネクションは使えないので閉じて読み込み側も終わらせる
+				log.Printf("Control connection write error: %v", err)
+				w.conn.Close()
+				return
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Send はメッセージをキューに積み、書き込みの結果を返す
+func (w *connWriter) Send(msg *Message) error {
+	req := outbound{msg: msg, result: make(chan error, 1)}
+
+	select {
+	case w.queue <- req:
+	case <-w.done:
+		return errWriterClosed
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-w.done:
+		// 停止と同時に書き込みが終わっている場合もある
+		select {
+		case err := <-req.result:
+			return err
+		default:
+			return errWriterClosed
+		}
+	}
+}
+
+// Close は送信ループを止める（キューに残ったメッセージは破棄される）
+func (w *connWriter) Close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 多数のストリームが同時にリレーへ送っても、ConnIDごとのフレームが順序どおりに届き、混ざらないこと
+func TestConcurrentForwardFromLocal(t *testing.T) {
+	const streams = 32
+	const repeat = 4000
+
+	// 接続ごとに異なる値を繰り返し送って閉じるローカルサービス
+	var accepted atomic.Int64
+	localPort := listenLocal(t, func(conn net.Conn) {
+		defer conn.Close()
+		token := fmt.Sprintf("%d;", accepted.Add(1))
+		conn.Write([]byte(strings.Repeat(token, repeat)))
+	})
+
+	relay := newFakeRelay(t, nil, TokenInfo{
+		ProtocolType: PROXY_TYPE_TCP,
+		LocalIP:      "127.0.0.1",
+		LocalPort:    localPort,
+		RemotePort:   40000,
+	})
+	startClient(t, relay.Addr(), Options{})
+	rc := relay.accept()
+
+	for i := range streams {
+		rc.send(Message{Type: MSG_TYPE_NEW_CONN, ProxyName: PROXY_TYPE_TCP, ConnID: fmt.Sprintf("conn-%d", i)})
+	}
+
+	received := make(map[string]*strings.Builder)
+	closed := make(map[string]bool)
+	for len(closed) < streams {
+		msg := rc.next()
+		switch msg.Type {
+		case MSG_TYPE_DATA:
+			if closed[msg.ConnID] {
+				t.Fatalf("data for %s after close", msg.ConnID)
+			}
+			if received[msg.ConnID] == nil {
+				received[msg.ConnID] = &strings.Builder{}
+			}
+			received[msg.ConnID].Write(msg.Data)
+		case MSG_TYPE_CLOSE:
+			closed[msg.ConnID] = true
+		}
+	}
+
+	tokens := make(map[string]string)
+	for connID, data := range received {
+		body := data.String()
+		token, _, _ := strings.Cut(body, ";")
+		token += ";"
+		if body != strings.Repeat(token, repeat) {
+			t.Fatalf("%s: frames from different streams were mixed or reordered (%d bytes)", connID, len(body))
+		}
+		if other, exists := tokens[token]; exists {
+			t.Fatalf("%s and %s received the same local connection", connID, other)
+		}
+		tokens[token] = connID
+	}
+	if len(received) != streams {
+		t.Fatalf("received data for %d streams, want %d", len(received), streams)
+	}
+}
+
+// 書き込みに失敗した場合は、送信した呼び出し元へエラーが返ること
+func TestConnWriterReportsWriteError(t *testing.T) {
+	local, remote := net.Pipe()
+	remote.Close()
+
+	w := newConnWriter(local, newBinaryCodec(local, local))
+	defer w.Close()
+
+	if err := w.Send(&Message{Type: MSG_TYPE_DATA, ConnID: "a", Data: []byte("x")}); err == nil {
+		t.Fatal("Send succeeded on a closed connection")
+	}
+	// 失敗した後の送信は送信ループが止まっていることを返す
+	if err := w.Send(&Message{Type: MSG_TYPE_DATA, ConnID: "a"}); !errors.Is(err, errWriterClosed) {
+		t.Fatalf("Send after failure: %v, want %v", err, errWriterClosed)
+	}
+}
@@ -0,0 +1,67 @@
+package minecraft
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ハンドシェイク後の状態
+const (
+	NextStateStatus   = 1 // サーバーリストのping
+	NextStateLogin    = 2 // ログイン
+	NextStateTransfer = 3 // 他のサーバーからの転送によるログイン(1.20.5以降)
+)
+
+// パケットID（ハンドシェイク・ログイン開始の時点のもの）
+const (
+	PacketHandshake  = 0x00
+	PacketLoginStart = 0x00
+)
+
+// ハンドシェイクパケットの中身
+type Handshake struct {
+	ProtocolVersion int32
+	ServerAddress   string // プレイヤーが接続に使ったホスト名
+	ServerPort      uint16
+	NextState       int32
+}
+
+// IsLogin はハンドシェイクの後にログインが始まるかを返す
+func (h Handshake) IsLogin() bool {
+	return h.NextState == NextStateLogin || h.NextState == NextStateTransfer
+}
+
+// ParseHandshake はハンドシェイクパケットの中身を解析する
+func ParseHandshake(payload []byte) (Handshake, error) {
+	r := packetReader{data: payload}
+	h := Handshake{
+		ProtocolVersion: r.varInt(),
+		ServerAddress:   cleanServerAddress(r.string(255)),
+		ServerPort:      r.uint16(),
+		NextState:       r.varInt(),
+	}
+	if r.err != nil {
+		return Handshake{}, fmt.Errorf("invalid handshake: %w", r.err)
+	}
+	if h.NextState < NextStateStatus || h.NextState > NextStateTransfer {
+		return Handshake{}, fmt.Errorf("invalid handshake: unknown next state %d", h.NextState)
+	}
+	return h, nil
+}
+
+// ParseLoginStart はログイン開始パケットの中身からプレイヤー名を取り出す
+// 1.19以降はプレイヤー名の後に署名やUUIDが続くが、ここでは使わない
+func ParseLoginStart(payload []byte) (string, error) {
+	r := packetReader{data: payload}
+	name := r.string(16)
+	if r.err != nil {
+		return "", fmt.Errorf("invalid login start: %w", r.err)
+	}
+	return name, nil
+}
+
+// cleanServerAddress はForgeやBungeeCordがホスト名の後ろに付け足す情報と末尾のドットを取り除く
+func cleanServerAddress(address string) string {
+	address, _, _ = strings.Cut(address, "\x00")
+	return strings.TrimSuffix(address, ".")
+}
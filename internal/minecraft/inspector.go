@@ -0,0 +1,113 @@
+package minecraft
+
+import "errors"
+
+// 解析を諦めるまでに溜めるデータ量
+// ハンドシェイクとログイン開始はどちらも数百バイトに収まる
+const maxInspectSize = 4096
+
+// 接続の先頭のパケットから分かった情報
+type ConnInfo struct {
+	Handshake
+	PlayerName string // ログインの場合のプレイヤー名（ログイン開始を受け取るまでは空）
+}
+
+// Version はクライアントのバージョン名を返す
+func (i ConnInfo) Version() string {
+	return VersionName(i.ProtocolVersion)
+}
+
+// Inspector はプレイヤーから送られてきた先頭のデータを覗き見て、ハンドシェイクとログイン開始を解析する
+//
+// データは書き換えずにそのままローカルサーバへ流す前提で、ここでは写しを溜めて読むだけ。
+// Minecraftの通信ではないと分かった時点や、必要な情報が揃った時点で解析をやめる
+type Inspector struct {
+	buffer       []byte
+	info         ConnInfo
+	hasHandshake bool
+	done         bool
+}
+
+func NewInspector() *Inspector {
+	return &Inspector{}
+}
+
+// Feed はプレイヤーから届いたデータを渡す
+// 新しくハンドシェイクかプレイヤー名が分かった場合にtrueを返す
+func (i *Inspector) Feed(data []byte) bool {
+	if i.done {
+		return false
+	}
+
+	if len(i.buffer) == 0 && len(data) > 0 && data[0] == 0xFE {
+		// 1.6以前の形式のサーバーリストのping
+		i.finish()
+		return false
+	}
+
+	i.buffer = append(i.buffer, data...)
+	updated := false
+	for !i.done {
+		id, payload, n, err := splitPacket(i.buffer)
+		if errors.Is(err, errIncomplete) {
+			if len(i.buffer) > maxInspectSize {
+				i.finish()
+			}
+			break
+		}
+		if err != nil {
+			i.finish()
+			break
+		}
+		i.buffer = i.buffer[n:]
+
+		if !i.hasHandshake {
+			if id != PacketHandshake {
+				i.finish()
+				break
+			}
+			handshake, err := ParseHandshake(payload)
+			if err != nil {
+				i.finish()
+				break
+			}
+			i.info.Handshake = handshake
+			i.hasHandshake = true
+			updated = true
+			if !handshake.IsLogin() {
+				// ステータス要求の場合はプレイヤー名は届かない
+				i.finish()
+			}
+			continue
+		}
+
+		if id == PacketLoginStart {
+			if name, err := ParseLoginStart(payload); err == nil {
+				i.info.PlayerName = name
+				updated = true
+			}
+		}
+		i.finish()
+	}
+	return updated
+}
+
+func (i *Inspector) finish() {
+	i.done = true
+	i.buffer = nil
+}
+
+// Done は解析を終えたかどうかを返す
+func (i *Inspector) Done() bool {
+	return i.done
+}
+
+// Info はこれまでに分かった情報を返す
+func (i *Inspector) Info() ConnInfo {
+	return i.info
+}
+
+// HasHandshake はハンドシェイクを解析できたかどうかを返す
+func (i *Inspector) HasHandshake() bool {
+	return i.hasHandshake
+}
@@ -0,0 +1,179 @@
+package minecraft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// パケットID（ステータス・ログインの状態のもの）
+const (
+	PacketStatusRequest   = 0x00
+	PacketStatusResponse  = 0x00
+	PacketPingRequest     = 0x01
+	PacketPongResponse    = 0x01
+	PacketLoginDisconnect = 0x00
+)
+
+// オフライン応答の1接続あたりの制限時間
+const offlineTimeout = 10 * time.Second
+
+// ローカルサーバが動いていないときにサーバーリストとログインへ返す内容
+type OfflineConfig struct {
+	MOTD              string // サーバーリストに表示する説明文（§による装飾可）
+	Favicon           string // サーバーアイコン（"data:image/png;base64,..." 形式、空なら無し）
+	VersionName       string // サーバーリストに表示するバージョン名（空なら "QuickPort"）
+	MaxPlayers        int
+	OnlinePlayers     int
+	DisconnectMessage string // ログインしようとしたプレイヤーに表示する切断理由
+
+	StartingMOTD    string // サーバを起動している間のサーバーリストの説明文
+	StartingMessage string // サーバを起動している間にログインしようとしたプレイヤーに表示する切断理由
+
+	// OnLogin はプレイヤーがログインしようとしたときに呼ばれる
+	// 空でない文字列を返すと、DisconnectMessageの代わりに切断理由として表示する
+	OnLogin func(info ConnInfo) string
+}
+
+func DefaultOfflineConfig() OfflineConfig {
+	return OfflineConfig{
+		MOTD:              "§eサーバーは現在停止中です",
+		VersionName:       "QuickPort",
+		MaxPlayers:        20,
+		DisconnectMessage: "サーバーは現在停止中です。しばらくしてから再度接続してください。",
+		StartingMOTD:      "§aサーバーを起動しています…",
+		StartingMessage:   "サーバーを起動しています。1分ほどしてから再度接続してください。",
+	}
+}
+
+type statusResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int32  `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+	} `json:"players"`
+	Description textComponent `json:"description"`
+	Favicon     string        `json:"favicon,omitempty"`
+}
+
+type textComponent struct {
+	Text string `json:"text"`
+}
+
+// ServeOffline はconnの相手をMinecraftサーバの代わりに応対する
+//
+// サーバーリストのpingにはOfflineConfigのMOTDを返し、ログインには切断理由を返して切断する。
+// 応対を終えるとconnを閉じ、ハンドシェイクで分かった情報を返す
+func ServeOffline(conn net.Conn, config OfflineConfig) (ConnInfo, error) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(offlineTimeout))
+
+	var info ConnInfo
+	reader := bufio.NewReader(conn)
+
+	id, payload, err := ReadPacket(reader)
+	if err != nil {
+		return info, err
+	}
+	if id != PacketHandshake {
+		return info, fmt.Errorf("unexpected packet 0x%02x before handshake", id)
+	}
+	info.Handshake, err = ParseHandshake(payload)
+	if err != nil {
+		return info, err
+	}
+
+	if info.IsLogin() {
+		id, payload, err := ReadPacket(reader)
+		if err != nil {
+			return info, err
+		}
+		if id == PacketLoginStart {
+			info.PlayerName, _ = ParseLoginStart(payload)
+		}
+		message := config.DisconnectMessage
+		if config.OnLogin != nil {
+			if override := config.OnLogin(info); override != "" {
+				message = override
+			}
+		}
+		return info, WriteLoginDisconnect(conn, message)
+	}
+
+	for {
+		id, payload, err := ReadPacket(reader)
+		if err != nil {
+			// pingを送らずに閉じるクライアントもいるので、ここでの切断はエラーにしない
+			return info, nil
+		}
+
+		switch id {
+		case PacketStatusRequest:
+			status, err := config.statusJSON(info.ProtocolVersion)
+			if err != nil {
+				return info, err
+			}
+			if err := WritePacket(conn, PacketStatusResponse, AppendString(nil, string(status))); err != nil {
+				return info, err
+			}
+		case PacketPingRequest:
+			// 受け取った値をそのまま返すと、クライアントが応答時間を表示する
+			return info, WritePacket(conn, PacketPongResponse, payload)
+		default:
+			return info, fmt.Errorf("unexpected status packet 0x%02x", id)
+		}
+	}
+}
+
+// WriteLoginDisconnect はログイン中のプレイヤーに切断理由を表示して切断させるパケットを書き込む
+func WriteLoginDisconnect(w io.Writer, message string) error {
+	reason, err := json.Marshal(textComponent{Text: message})
+	if err != nil {
+		return err
+	}
+	return WritePacket(w, PacketLoginDisconnect, AppendString(nil, string(reason)))
+}
+
+func (config OfflineConfig) statusJSON(protocol int32) ([]byte, error) {
+	var status statusResponse
+	status.Version.Name = config.VersionName
+	if status.Version.Name == "" {
+		status.Version.Name = "QuickPort"
+	}
+	// クライアントと同じ番号を返し、バージョン違いの表示にならないようにする
+	status.Version.Protocol = protocol
+	status.Players.Max = config.MaxPlayers
+	status.Players.Online = config.OnlinePlayers
+	status.Description = textComponent{Text: config.MOTD}
+	status.Favicon = config.Favicon
+	return json.Marshal(status)
+}
+
+// LoadFavicon はPNGファイルを読み込み、サーバーアイコンとして使える形式に変換する
+// Minecraftは64x64のPNGしか表示しない
+func LoadFavicon(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	image, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("サーバーアイコンがPNGではありません: %w", err)
+	}
+	if image.Width != 64 || image.Height != 64 {
+		return "", fmt.Errorf("サーバーアイコンは64x64である必要があります（%dx%d）", image.Width, image.Height)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,191 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 圧縮前（ハンドシェイク・ログイン開始時点）のパケットの最大長
+// これより長いものはMinecraftの通信ではないとみなす
+const MaxPacketSize = 2 * 1024 * 1024
+
+var (
+	ErrVarIntTooLong  = errors.New("VarInt is too long")
+	ErrPacketTooLarge = errors.New("packet is too large")
+	errIncomplete     = errors.New("incomplete packet")
+)
+
+// AppendVarInt はVarIntをbufに追加する
+func AppendVarInt(buf []byte, value int32) []byte {
+	v := uint32(value)
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// decodeVarInt はbufの先頭からVarIntを読み取り、値と消費したバイト数を返す
+// データが足りない場合はerrIncompleteを返す
+func decodeVarInt(buf []byte) (int32, int, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		if i >= len(buf) {
+			return 0, 0, errIncomplete
+		}
+		b := buf[i]
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(value), i + 1, nil
+		}
+	}
+	return 0, 0, ErrVarIntTooLong
+}
+
+// ReadVarInt はrからVarIntを1つ読み取る
+func ReadVarInt(r io.ByteReader) (int32, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(value), nil
+		}
+	}
+	return 0, ErrVarIntTooLong
+}
+
+// AppendString は長さ(VarInt)付きの文字列をbufに追加する
+func AppendString(buf []byte, s string) []byte {
+	buf = AppendVarInt(buf, int32(len(s)))
+	return append(buf, s...)
+}
+
+// packetReader はパケットの中身を先頭から順に読み取る
+type packetReader struct {
+	data []byte
+	err  error
+}
+
+func (r *packetReader) varInt() int32 {
+	if r.err != nil {
+		return 0
+	}
+	value, n, err := decodeVarInt(r.data)
+	if err != nil {
+		r.err = fmt.Errorf("invalid VarInt: %w", err)
+		return 0
+	}
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *packetReader) string(maxLength int) string {
+	length := r.varInt()
+	if r.err != nil {
+		return ""
+	}
+	// 文字数の上限はUTF-16単位だが、バイト数は最大でその4倍になる
+	if length < 0 || int(length) > maxLength*4 || int(length) > len(r.data) {
+		r.err = fmt.Errorf("invalid string length: %d", length)
+		return ""
+	}
+	s := string(r.data[:length])
+	r.data = r.data[length:]
+	return s
+}
+
+func (r *packetReader) uint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 2 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	value := binary.BigEndian.Uint16(r.data)
+	r.data = r.data[2:]
+	return value
+}
+
+// splitPacket はbufの先頭から圧縮されていないパケットを1つ取り出す
+// 戻り値はパケットID、中身、消費したバイト数。データが足りない場合はerrIncompleteを返す
+func splitPacket(buf []byte) (int32, []byte, int, error) {
+	length, n, err := decodeVarInt(buf)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if length <= 0 || length > MaxPacketSize {
+		return 0, nil, 0, ErrPacketTooLarge
+	}
+	if len(buf)-n < int(length) {
+		return 0, nil, 0, errIncomplete
+	}
+
+	body := buf[n : n+int(length)]
+	id, idLength, err := decodeVarInt(body)
+	if err != nil {
+		if errors.Is(err, errIncomplete) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, 0, err
+	}
+	return id, body[idLength:], n + int(length), nil
+}
+
+// ReadPacket はrから圧縮されていないパケットを1つ読み取り、パケットIDと中身を返す
+func ReadPacket(r io.Reader) (int32, []byte, error) {
+	byteReader, ok := r.(io.ByteReader)
+	if !ok {
+		byteReader = &singleByteReader{r: r}
+	}
+
+	length, err := ReadVarInt(byteReader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > MaxPacketSize {
+		return 0, nil, ErrPacketTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	id, n, err := decodeVarInt(body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid packet ID: %w", err)
+	}
+	return id, body[n:], nil
+}
+
+// WritePacket はパケットIDと中身を圧縮されていないパケットとしてwへ書き込む
+func WritePacket(w io.Writer, id int32, payload []byte) error {
+	body := AppendVarInt(nil, id)
+	body = append(body, payload...)
+
+	var buf bytes.Buffer
+	buf.Write(AppendVarInt(nil, int32(len(body))))
+	buf.Write(body)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type singleByteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (r *singleByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.r, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
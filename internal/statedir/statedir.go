@@ -0,0 +1,223 @@
+// Package statedir はトークンやアカウント情報を保存するユーザーごとのディレクトリを扱う
+//
+// 以前は起動したフォルダ（カレントディレクトリ）に保存していたため、別のフォルダから起動すると
+// アカウントが見つからず、トークンも他のユーザーから読めた。
+// カレントディレクトリ（と、ダブルクリックで起動した場合の置き場所である実行ファイルのフォルダ）に
+// 残った以前のファイルは、初回の起動で一度だけ移動する。
+// 既定の場所は os.UserConfigDir の下（Linuxでは $XDG_CONFIG_HOME/QuickPort か ~/.config/QuickPort、
+// Windowsでは %AppData%\QuickPort）で、QUICKPORT_STATE_DIR で変更できる
+package statedir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 保存先を変更する環境変数
+const DirEnv = "QUICKPORT_STATE_DIR"
+
+// 保存先のディレクトリ名
+const appName = "QuickPort"
+
+// 保存するファイル
+const (
+	TokenFile    = "token"
+	AccountsFile = "accounts.ini"
+	LogFile      = "qp.log"
+)
+
+// 以前のファイルを移動し終えたことを記録するファイル
+const migratedFile = ".migrated"
+
+// パーミッション
+const (
+	DirPerm    fs.FileMode = 0700
+	SecretPerm fs.FileMode = 0600 // トークンなど、本人以外に読まれてはいけないファイル
+	FilePerm   fs.FileMode = 0644
+)
+
+// 本人だけが読めるようにするファイル
+var secretFiles = map[string]bool{
+	TokenFile:    true,
+	AccountsFile: true,
+}
+
+var (
+	dirOnce sync.Once
+	dir     string
+)
+
+// Dir は保存先のディレクトリを返す
+// ユーザーの設定ディレクトリが分からない場合はカレントディレクトリを使う
+func Dir() string {
+	dirOnce.Do(func() {
+		if env := os.Getenv(DirEnv); env != "" {
+			dir = env
+			return
+		}
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			log.Printf("Failed to find user config dir, using current directory: %v", err)
+			dir = "."
+			return
+		}
+		dir = filepath.Join(configDir, appName)
+	})
+	return dir
+}
+
+// Path は保存先のディレクトリにあるnameのパスを返す
+func Path(name string) string {
+	return filepath.Join(Dir(), name)
+}
+
+// Perm はnameを書き込むときのパーミッションを返す
+func Perm(name string) fs.FileMode {
+	if secretFiles[filepath.Base(name)] {
+		return SecretPerm
+	}
+	return FilePerm
+}
+
+// WriteFile は一時ファイルに書いてから置き換え、書き込み途中で終了してもファイルが壊れないようにする
+// 置き換える前にpermを設定するので、他のユーザーから読める瞬間は無い
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), DirPerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Prepare は保存先のディレクトリを作り、カレントディレクトリと実行ファイルのフォルダに残っている以前のファイルを移動する
+// 移動は一度だけ行い、保存先にすでにファイルがある場合は移動しない。移動したファイル名を返す。
+// 以前のバージョンが作ったトークンなども本人だけが読めるようにする
+func Prepare(names ...string) ([]string, error) {
+	return prepare(legacyDirs(), names...)
+}
+
+// legacyDirs は以前のバージョンがファイルを保存していたフォルダを、優先する順に返す
+// 以前はカレントディレクトリに保存していたので、まずそこを探し、次に実行ファイルのフォルダを探す
+func legacyDirs() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	} else {
+		log.Printf("Failed to find working directory: %v", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Printf("Failed to find executable: %v", err)
+		return dirs
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	if dir := filepath.Dir(executable); len(dirs) == 0 || dir != dirs[0] {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// prepare は srcDirs にある以前のファイルを保存先へ移動し、移動を終えたことを記録する
+// 同じファイルが複数のフォルダにある場合は先のフォルダのものを使う
+// 記録がある場合は移動せず、パーミッションだけを直す
+func prepare(srcDirs []string, names ...string) ([]string, error) {
+	if err := os.MkdirAll(Dir(), DirPerm); err != nil {
+		return nil, err
+	}
+	_, err := os.Stat(Path(migratedFile))
+	done := err == nil || len(srcDirs) == 0
+
+	var moved []string
+	var errs []error
+	for _, name := range names {
+		for _, srcDir := range srcDirs {
+			if done {
+				break
+			}
+			ok, err := migrate(srcDir, name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s の移動に失敗しました: %w", name, err))
+				break
+			}
+			if ok {
+				moved = append(moved, name)
+				break
+			}
+		}
+		if secretFiles[name] {
+			if err := os.Chmod(Path(name), SecretPerm); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	// 移動できなかったファイルは次の起動でもう一度試す
+	if !done && len(errs) == 0 {
+		if err := WriteFile(Path(migratedFile), nil, FilePerm); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return moved, errors.Join(errs...)
+}
+
+// migrate は srcDir のnameを保存先へ移動する
+func migrate(srcDir, name string) (bool, error) {
+	src, err := filepath.Abs(filepath.Join(srcDir, name))
+	if err != nil {
+		return false, err
+	}
+	dst, err := filepath.Abs(Path(name))
+	if err != nil {
+		return false, err
+	}
+	if src == dst {
+		return false, nil
+	}
+	if _, err := os.Stat(src); err != nil {
+		return false, nil
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return false, nil
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return true, nil
+	}
+
+	// 別のドライブなどで移動できない場合はコピーしてから消す
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	if err := WriteFile(dst, data, Perm(name)); err != nil {
+		return false, err
+	}
+	if err := os.Remove(src); err != nil {
+		log.Printf("Failed to remove %s: %v", src, err)
+	}
+	return true, nil
+}
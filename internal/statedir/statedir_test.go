@@ -0,0 +1,110 @@
+package statedir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// useTempDir は保存先をテスト用の一時ディレクトリにする
+func useTempDir(t *testing.T) {
+	t.Helper()
+	t.Setenv(DirEnv, t.TempDir())
+	dirOnce = sync.Once{}
+	t.Cleanup(func() { dirOnce = sync.Once{} })
+}
+
+// writeOld は以前の置き場所にファイルを書く
+func writeOld(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 以前の置き場所のファイルは一度だけ移動し、その後に置かれたファイルは移動しないこと
+func TestPrepareMigratesOnce(t *testing.T) {
+	useTempDir(t)
+	srcDir := t.TempDir()
+	writeOld(t, srcDir, TokenFile, "old-token")
+
+	moved, err := prepare([]string{srcDir}, TokenFile, AccountsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 || moved[0] != TokenFile {
+		t.Fatalf("moved = %v, want [%s]", moved, TokenFile)
+	}
+	data, err := os.ReadFile(Path(TokenFile))
+	if err != nil || string(data) != "old-token" {
+		t.Fatalf("migrated token = %q, %v", data, err)
+	}
+
+	// 2回目の起動では同じ場所にファイルがあっても移動しない
+	writeOld(t, srcDir, AccountsFile, "[Account]\n")
+	moved, err = prepare([]string{srcDir}, TokenFile, AccountsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 0 {
+		t.Fatalf("moved on second run = %v", moved)
+	}
+	if _, err := os.Stat(filepath.Join(srcDir, AccountsFile)); err != nil {
+		t.Fatalf("file in the old location was touched: %v", err)
+	}
+}
+
+// カレントディレクトリに残った以前のファイルを移動し、本人だけが読めるようにすること
+func TestPrepareMigratesWorkingDirectory(t *testing.T) {
+	useTempDir(t)
+	wd := t.TempDir()
+	t.Chdir(wd)
+	writeOld(t, wd, TokenFile, "old-token")
+	writeOld(t, wd, AccountsFile, "[Account]\nEmail = owner@example.com\n")
+	writeOld(t, wd, LogFile, "old log\n")
+
+	moved, err := Prepare(TokenFile, AccountsFile, LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 3 {
+		t.Fatalf("moved = %v, want all three files", moved)
+	}
+	for _, name := range moved {
+		if _, err := os.Stat(filepath.Join(wd, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s is still in the working directory: %v", name, err)
+		}
+	}
+	info, err := os.Stat(Path(AccountsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != SecretPerm {
+		t.Fatalf("accounts.ini perm = %o, want %o", perm, SecretPerm)
+	}
+}
+
+// 複数の置き場所に同じファイルがある場合は先の置き場所のものを移動すること
+func TestPreparePrefersFirstDir(t *testing.T) {
+	useTempDir(t)
+	wd, exeDir := t.TempDir(), t.TempDir()
+	writeOld(t, wd, TokenFile, "cwd-token")
+	writeOld(t, exeDir, TokenFile, "exe-token")
+	writeOld(t, exeDir, AccountsFile, "[Account]\n")
+
+	moved, err := prepare([]string{wd, exeDir}, TokenFile, AccountsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 2 {
+		t.Fatalf("moved = %v", moved)
+	}
+	if data, _ := os.ReadFile(Path(TokenFile)); string(data) != "cwd-token" {
+		t.Fatalf("migrated token = %q, want cwd-token", data)
+	}
+	if _, err := os.Stat(filepath.Join(exeDir, TokenFile)); err != nil {
+		t.Fatalf("token in the second dir was touched: %v", err)
+	}
+}
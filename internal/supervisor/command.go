@@ -0,0 +1,50 @@
+package supervisor
+
+import (
+	"errors"
+	"strings"
+)
+
+var errEmptyCommand = errors.New("起動コマンドが設定されていません")
+
+// splitCommand は起動コマンドを引数に分割する
+// シェルは通さず、空白で区切る。空白を含む引数は "..." か '...' で囲む
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("起動コマンドの引用符が閉じられていません")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errEmptyCommand
+	}
+	return args, nil
+}
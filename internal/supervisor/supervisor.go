@@ -0,0 +1,288 @@
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ローカルサーバのプロセスの管理設定
+type Config struct {
+	Command      string        // 起動コマンド（例: java -Xmx2G -jar paper.jar nogui）
+	Dir          string        // 作業ディレクトリ（空ならQuickPortと同じ）
+	Addr         string        // 起動完了を確認するアドレス（空ならWakeで渡されたアドレス）
+	IdleTimeout  time.Duration // 接続が無くなってから停止するまでの時間（0なら自動で停止しない）
+	StartTimeout time.Duration // ポートが接続を受け付けるまで待つ時間
+	StopTimeout  time.Duration // 停止コマンドを送ってから強制終了するまでの時間
+	StopCommand  string        // 標準入力へ送る停止コマンド
+	Output       io.Writer     // サーバの標準出力・標準エラーの出力先（nilならログ）
+}
+
+func (c Config) withDefaults() Config {
+	if c.StartTimeout <= 0 {
+		c.StartTimeout = 5 * time.Minute
+	}
+	if c.StopTimeout <= 0 {
+		c.StopTimeout = time.Minute
+	}
+	if c.StopCommand == "" {
+		c.StopCommand = "stop"
+	}
+	if c.Output == nil {
+		c.Output = log.Writer()
+	}
+	return c
+}
+
+// プロセスの状態
+type State int
+
+const (
+	StateStopped  State = iota // 停止中
+	StateStarting              // 起動してからポートが開くまで
+	StateRunning               // 稼働中
+	StateStopping              // 停止コマンドを送って終了を待っている
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "Stopped"
+	case StateStarting:
+		return "Starting"
+	case StateRunning:
+		return "Running"
+	case StateStopping:
+		return "Stopping"
+	}
+	return "Unknown"
+}
+
+// 状態確認の間隔
+const checkInterval = time.Second
+
+// Supervisor はプレイヤーが来たときにローカルサーバを起動し、誰もいなくなったら停止する
+type Supervisor struct {
+	config Config
+
+	mutex       sync.Mutex
+	state       State
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	exited      chan struct{} // 現在のプロセスが終了すると閉じられる
+	addr        string        // 起動完了を確認するアドレス
+	connections int
+	idleSince   time.Time
+	wakeAgain   bool // 停止中に来たプレイヤーのために、終了後にもう一度起動する
+}
+
+func New(config Config) *Supervisor {
+	return &Supervisor{config: config.withDefaults()}
+}
+
+// State は現在の状態を返す
+func (s *Supervisor) State() State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// Starting は起動が完了するまでの間trueを返す
+// 停止処理中にプレイヤーが来た場合も、終了後に起動し直すのでtrueになる
+func (s *Supervisor) Starting() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state == StateStarting || (s.state == StateStopping && s.wakeAgain)
+}
+
+// Wake はプレイヤーがログインしようとしたときに呼ばれ、停止中ならサーバを起動する
+// localAddr は接続に失敗したローカルサーバのアドレスで、Config.Addrが空の場合に起動完了の確認に使う
+func (s *Supervisor) Wake(localAddr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.addr == "" {
+		s.addr = s.config.Addr
+		if s.addr == "" {
+			s.addr = localAddr
+		}
+	}
+
+	switch s.state {
+	case StateStopped:
+		if err := s.start(); err != nil {
+			log.Printf("Failed to start local server: %v", err)
+		}
+	case StateStopping:
+		s.wakeAgain = true
+	}
+}
+
+// start はプロセスを起動する（mutexを保持して呼ぶ）
+func (s *Supervisor) start() error {
+	args, err := splitCommand(s.config.Command)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = s.config.Dir
+	cmd.Stdout = s.config.Output
+	cmd.Stderr = s.config.Output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	log.Printf("Starting local server (pid %d): %s", cmd.Process.Pid, s.config.Command)
+	s.cmd = cmd
+	s.stdin = stdin
+	s.exited = make(chan struct{})
+	s.state = StateStarting
+	s.wakeAgain = false
+
+	go s.wait(cmd, s.exited)
+	go s.watch(s.exited)
+	return nil
+}
+
+// wait はプロセスの終了を待って状態を戻す
+func (s *Supervisor) wait(cmd *exec.Cmd, exited chan struct{}) {
+	err := cmd.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err != nil {
+		log.Printf("Local server exited: %v", err)
+	} else {
+		log.Printf("Local server exited")
+	}
+	s.state = StateStopped
+	s.cmd = nil
+	s.stdin = nil
+	close(exited)
+
+	if s.wakeAgain {
+		if err := s.start(); err != nil {
+			log.Printf("Failed to restart local server: %v", err)
+		}
+	}
+}
+
+// watch は起動完了と無人状態を監視する
+func (s *Supervisor) watch(exited chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	for {
+		select {
+		case <-exited:
+			return
+		case now := <-ticker.C:
+			switch s.State() {
+			case StateStarting:
+				if s.ready() {
+					s.mutex.Lock()
+					if s.state == StateStarting {
+						s.state = StateRunning
+						s.idleSince = now
+					}
+					s.mutex.Unlock()
+					log.Printf("Local server is ready (%v)", now.Sub(started).Round(time.Second))
+				} else if now.Sub(started) > s.config.StartTimeout {
+					log.Printf("Local server did not open %s within %v", s.addr, s.config.StartTimeout)
+					go s.Stop()
+				}
+			case StateRunning:
+				if s.idleFor(now) {
+					log.Printf("No connections for %v. Stopping local server...", s.config.IdleTimeout)
+					go s.Stop()
+				}
+			}
+		}
+	}
+}
+
+// ready はローカルサーバのポートが接続を受け付けるかを確認する
+func (s *Supervisor) ready() bool {
+	s.mutex.Lock()
+	addr := s.addr
+	s.mutex.Unlock()
+
+	if addr == "" {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", addr, checkInterval)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (s *Supervisor) idleFor(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.config.IdleTimeout > 0 &&
+		s.connections == 0 &&
+		now.Sub(s.idleSince) >= s.config.IdleTimeout
+}
+
+// SetConnections はローカルサーバへの接続数が変わるたびに呼ばれる
+func (s *Supervisor) SetConnections(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n == 0 && s.connections > 0 {
+		s.idleSince = time.Now()
+	}
+	s.connections = n
+}
+
+// Stop は停止コマンドを送ってサーバを止め、終了するまで待つ
+// StopTimeoutを過ぎても終了しない場合は強制終了する
+func (s *Supervisor) Stop() error {
+	s.mutex.Lock()
+	if s.state != StateStarting && s.state != StateRunning {
+		// 停止処理中なら、終了後に起動し直さずにそのまま待つ
+		s.wakeAgain = false
+		exited := s.exited
+		s.mutex.Unlock()
+		if exited != nil {
+			<-exited
+		}
+		return nil
+	}
+
+	s.state = StateStopping
+	s.wakeAgain = false
+	cmd, stdin, exited := s.cmd, s.stdin, s.exited
+	s.mutex.Unlock()
+
+	log.Printf("Sending %q to local server", s.config.StopCommand)
+	if _, err := fmt.Fprintln(stdin, s.config.StopCommand); err != nil {
+		log.Printf("Failed to send stop command: %v", err)
+	}
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(s.config.StopTimeout):
+		log.Printf("Local server did not stop within %v. Killing...", s.config.StopTimeout)
+		if err := cmd.Process.Kill(); err != nil {
+			return err
+		}
+		<-exited
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 偽のサーバが待ち受けるアドレスを渡す環境変数
+const fakeServerEnv = "QUICKPORT_FAKE_SERVER_ADDR"
+
+// テストで状態の変化を待つ時間の上限
+const testTimeout = 10 * time.Second
+
+// TestFakeServerProcess は偽のMinecraftサーバとして動く（テストのバイナリを起動コマンドに使う）
+// 少し遅れてポートを開き、標準入力に stop が来たら終了する
+func TestFakeServerProcess(t *testing.T) {
+	addr := os.Getenv(fakeServerEnv)
+	if addr == "" {
+		t.Skip("fake server process only")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println("listen failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Done! For help, type \"help\"")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if scanner.Text() == "stop" {
+			fmt.Println("Stopping the server")
+			listener.Close()
+			os.Exit(0)
+		}
+	}
+	os.Exit(1)
+}
+
+// syncBuffer は複数のgoroutineから書き込めるバッファ
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// newFakeServer は偽のサーバを起動する Supervisor と、サーバが待ち受けるアドレスを返す
+func newFakeServer(t *testing.T, idleTimeout time.Duration) (*Supervisor, string, *syncBuffer) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	// 子プロセスは環境変数を引き継ぐ
+	t.Setenv(fakeServerEnv, addr)
+	output := &syncBuffer{}
+	s := New(Config{
+		Command:     `"` + os.Args[0] + `" -test.run=^TestFakeServerProcess$`,
+		IdleTimeout: idleTimeout,
+		StopTimeout: testTimeout,
+		Output:      output,
+	})
+	t.Cleanup(func() { s.Stop() })
+	return s, addr, output
+}
+
+// waitState は状態が want になるまで待つ
+func waitState(t *testing.T, s *Supervisor, want State) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for s.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", s.State(), want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// 起動してポートが開くと稼働中になり、Stopで停止コマンドを送って終了を待つこと
+func TestStartReadyStop(t *testing.T) {
+	s, addr, output := newFakeServer(t, 0)
+
+	s.Wake(addr)
+	if !s.Starting() {
+		t.Fatalf("state after Wake = %s, want %s", s.State(), StateStarting)
+	}
+	waitState(t, s, StateRunning)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("fake server is not listening: %v", err)
+	}
+	conn.Close()
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if s.State() != StateStopped {
+		t.Fatalf("state after Stop = %s, want %s", s.State(), StateStopped)
+	}
+	if !strings.Contains(output.String(), "Stopping the server") {
+		t.Fatalf("stop command was not delivered, output:\n%s", output.String())
+	}
+}
+
+// 接続が無くなってから IdleTimeout が過ぎると自動で停止すること
+func TestStopWhenIdle(t *testing.T) {
+	s, addr, output := newFakeServer(t, time.Second)
+
+	s.Wake(addr)
+	waitState(t, s, StateRunning)
+
+	// プレイヤーがいる間は止めない
+	s.SetConnections(1)
+	time.Sleep(2500 * time.Millisecond)
+	if s.State() != StateRunning {
+		t.Fatalf("state with a player = %s, want %s", s.State(), StateRunning)
+	}
+
+	s.SetConnections(0)
+	waitState(t, s, StateStopped)
+	if !strings.Contains(output.String(), "Stopping the server") {
+		t.Fatalf("stop command was not delivered, output:\n%s", output.String())
+	}
+}
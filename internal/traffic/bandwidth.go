@@ -0,0 +1,58 @@
+package traffic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 帯域の表記（数値、接頭辞、単位）
+var bandwidthPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([kKmMgG]?)(bps|b/s|Bps|B/s)$`)
+
+// ParseBandwidth は "10Mbps" や "1MB/s" のような帯域の表記を1秒あたりのバイト数に変換する
+// 小文字のbはビット、大文字のBはバイトとして扱う。空文字や "unlimited" の場合は0（無制限）を返す
+func ParseBandwidth(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	if s == "" || s == "0" || strings.EqualFold(s, "unlimited") {
+		return 0, nil
+	}
+
+	match := bandwidthPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("帯域の形式が正しくありません: %s", value)
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("帯域の形式が正しくありません: %s", value)
+	}
+
+	switch strings.ToLower(match[2]) {
+	case "k":
+		number *= 1e3
+	case "m":
+		number *= 1e6
+	case "g":
+		number *= 1e9
+	}
+	if strings.HasPrefix(match[3], "b") {
+		number /= 8
+	}
+	return int64(number), nil
+}
+
+// FormatBytes はバイト数を読みやすい単位で表す
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f PB", value)
+}
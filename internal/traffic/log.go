@@ -0,0 +1,102 @@
+package traffic
+
+import (
+	"QuickPort/internal/statedir"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// 記録ファイルの名前
+const FileName = "traffic.json"
+
+// DefaultPath は状態ディレクトリにある既定の記録ファイルのパスを返す
+func DefaultPath() string {
+	return statedir.Path(FileName)
+}
+
+// 日付の書式
+const dateLayout = "2006-01-02"
+
+// 1日分の通信量
+type Day struct {
+	In  uint64 `json:"in"`  // プレイヤーからローカルサーバへ（リレーから受信）
+	Out uint64 `json:"out"` // ローカルサーバからプレイヤーへ（リレーへ送信）
+}
+
+// Log は日ごとの通信量をファイルに記録する
+type Log struct {
+	path  string
+	mutex sync.Mutex
+	days  map[string]Day
+}
+
+// Open は記録ファイルを読み込む。ファイルが無い場合は空の記録を返す
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, days: make(map[string]Day)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l.days); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// Add はtの日付の通信量に加算してファイルへ保存する
+func (l *Log) Add(t time.Time, in, out uint64) error {
+	if in == 0 && out == 0 {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := t.Format(dateLayout)
+	day := l.days[key]
+	day.In += in
+	day.Out += out
+	l.days[key] = day
+
+	return l.save()
+}
+
+// save は一時ファイルに書いてから置き換え、書き込み途中で終了しても記録が壊れないようにする
+func (l *Log) save() error {
+	data, err := json.MarshalIndent(l.days, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(l.path, data, statedir.FilePerm)
+}
+
+// Day はtの日付の通信量を返す
+func (l *Log) Day(t time.Time) Day {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.days[t.Format(dateLayout)]
+}
+
+// Month はtの月の通信量の合計を返す
+func (l *Log) Month(t time.Time) Day {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	prefix := t.Format("2006-01-")
+	var total Day
+	for key, day := range l.days {
+		if len(key) == len(dateLayout) && key[:len(prefix)] == prefix {
+			total.In += day.In
+			total.Out += day.Out
+		}
+	}
+	return total
+}
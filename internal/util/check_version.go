@@ -1,7 +1,7 @@
 package util
 
 import (
-	"QuickPort/share"
+	"QuickPort/internal/config"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,12 +12,13 @@ import (
 // 最新版があるかどうかを確認する関数
 func GetNewVersion(currentVer string) (string, error) {
 	// githubのAPIを使用して最新バージョンを取得
-	request, err := http.NewRequest("GET", share.RELEASE_ENDPOINT, nil)
+	request, err := http.NewRequest("GET", config.Get().API.ReleaseEndpoint, nil)
 	if err != nil {
 		return "", err
 	}
 	request.Header.Set("Accept", "application/vnd.github.v3+json")
-	response, err := http.DefaultClient.Do(request)
+	client := &http.Client{Timeout: config.Get().Timeouts.API}
+	response, err := client.Do(request)
 	if err != nil {
 		return "", err
 	}
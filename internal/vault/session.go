@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ヘッドレスで使うときにパスフレーズを渡す環境変数
+const PassphraseEnv = "QUICKPORT_VAULT_PASSPHRASE"
+
+// ErrLocked は保管庫をまだ開いていないことを表す
+var ErrLocked = errors.New("保管庫がロックされています。パスフレーズを入力するか、環境変数 " + PassphraseEnv + " を設定してください")
+
+// このプロセスで開いた保管庫（TUIでは起動してから1回だけパスフレーズを入力する）
+var (
+	sessionMutex sync.Mutex
+	session      *Vault
+)
+
+// Unlock は既定の保管庫をパスフレーズで開き、以降 Session で使えるようにする
+func Unlock(passphrase string) error {
+	v, err := Open(DefaultPath(), passphrase)
+	if err != nil {
+		return err
+	}
+
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	session = v
+	return nil
+}
+
+// Session は開いた保管庫を返す
+// まだ開いていない場合は環境変数 QUICKPORT_VAULT_PASSPHRASE のパスフレーズで開く
+func Session() (*Vault, error) {
+	sessionMutex.Lock()
+	v := session
+	sessionMutex.Unlock()
+	if v != nil {
+		return v, nil
+	}
+
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return nil, ErrLocked
+	}
+	if err := Unlock(passphrase); err != nil {
+		return nil, err
+	}
+	return Session()
+}
+
+// Unlocked は保管庫を開いているかどうかを返す
+func Unlocked() bool {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	return session != nil
+}
@@ -0,0 +1,223 @@
+// Package vault はトークンをパスフレーズで暗号化して保存する保管庫
+//
+// パスフレーズから scrypt で鍵を作り、AES-256-GCM で暗号化する。
+// ファイルには鍵の導出に使うソルトとパラメータ、暗号文だけを書き、パスフレーズや鍵は保存しない。
+// 書き換えるたびに新しいnonceで全体を暗号化し直す
+package vault
+
+import (
+	"QuickPort/internal/statedir"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 保管庫のファイル名
+const FileName = "vault.json"
+
+// ファイルの形式の版
+const fileVersion = 1
+
+// 鍵の導出に使う scrypt のパラメータ（N=2^15, r=8, p=1 で100ms前後）
+const (
+	kdfName       = "scrypt"
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	keyLen        = 32 // AES-256
+	saltLen       = 16
+	minPassphrase = 8 // 新しく作るときのパスフレーズの最短の長さ
+)
+
+var (
+	ErrWrongPassphrase = errors.New("パスフレーズが違います")
+	ErrWeakPassphrase  = fmt.Errorf("パスフレーズは%d文字以上にしてください", minPassphrase)
+)
+
+// DefaultPath は状態ディレクトリにある保管庫のパスを返す
+func DefaultPath() string {
+	return statedir.Path(FileName)
+}
+
+// Exists はpathに保管庫があるかどうかを返す
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// file は保管庫のファイルの内容
+type file struct {
+	Version int       `json:"version"`
+	KDF     kdfParams `json:"kdf"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"` // 項目の名前 → 値 のJSONを暗号化したもの
+}
+
+// kdfParams はパスフレーズから鍵を作るときのパラメータ
+type kdfParams struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// deriveKey はパスフレーズから鍵を作る
+// 壊れたり書き換えられたりしたファイルで大量のメモリや時間を使わないように、作るときと同じパラメータだけを受け付ける
+func (k kdfParams) deriveKey(passphrase string) ([]byte, error) {
+	if k.Name != kdfName {
+		return nil, fmt.Errorf("対応していない鍵の導出方式です: %s", k.Name)
+	}
+	if k.N != scryptN || k.R != scryptR || k.P != scryptP {
+		return nil, fmt.Errorf("対応していない鍵の導出パラメータです (N=%d, r=%d, p=%d)", k.N, k.R, k.P)
+	}
+	if len(k.Salt) != saltLen {
+		return nil, fmt.Errorf("ソルトの長さが不正です (%dバイト)", len(k.Salt))
+	}
+	return scrypt.Key([]byte(passphrase), k.Salt, k.N, k.R, k.P, keyLen)
+}
+
+// Vault は開いた保管庫
+type Vault struct {
+	path    string
+	mutex   sync.Mutex
+	kdf     kdfParams
+	aead    cipher.AEAD
+	entries map[string]string
+}
+
+// Open はpathの保管庫をパスフレーズで開く
+// 保管庫が無い場合はそのパスフレーズで新しく作る（最初に Set したときに書き出す）
+func Open(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, errors.New("パスフレーズを入力してください")
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return create(path, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s を読み取れません: %w", path, err)
+	}
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("%s は対応していない形式です (version %d)", path, f.Version)
+	}
+
+	v := &Vault{path: path, kdf: f.KDF}
+	if err := v.setKey(passphrase); err != nil {
+		return nil, fmt.Errorf("%s を開けません: %w", path, err)
+	}
+	if len(f.Nonce) != v.aead.NonceSize() {
+		return nil, fmt.Errorf("%s を開けません: nonceの長さが不正です (%dバイト)", path, len(f.Nonce))
+	}
+	plaintext, err := v.aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		// 認証タグが合わない場合はパスフレーズの誤りか改ざん
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &v.entries); err != nil {
+		return nil, fmt.Errorf("%s を読み取れません: %w", path, err)
+	}
+	if v.entries == nil {
+		v.entries = make(map[string]string)
+	}
+	return v, nil
+}
+
+// create は新しいソルトで空の保管庫を作る
+func create(path, passphrase string) (*Vault, error) {
+	if len([]rune(passphrase)) < minPassphrase {
+		return nil, ErrWeakPassphrase
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	v := &Vault{
+		path:    path,
+		kdf:     kdfParams{Name: kdfName, N: scryptN, R: scryptR, P: scryptP, Salt: salt},
+		entries: make(map[string]string),
+	}
+	if err := v.setKey(passphrase); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Vault) setKey(passphrase string) error {
+	key, err := v.kdf.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	v.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// Get はnameの値を返す
+func (v *Vault) Get(name string) (string, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	value, ok := v.entries[name]
+	return value, ok
+}
+
+// Set はnameに値を保存し、保管庫を書き出す
+func (v *Vault) Set(name, value string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.entries[name] = value
+	return v.save()
+}
+
+// Delete はnameを削除し、保管庫を書き出す
+func (v *Vault) Delete(name string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if _, ok := v.entries[name]; !ok {
+		return nil
+	}
+	delete(v.entries, name)
+	return v.save()
+}
+
+// save は新しいnonceで全体を暗号化し、本人だけが読めるファイルに書き出す
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(file{
+		Version: fileVersion,
+		KDF:     v.kdf,
+		Nonce:   nonce,
+		Data:    v.aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(v.path, data, statedir.SecretPerm)
+}
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"QuickPort/internal/statedir"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+func TestMain(m *testing.M) {
+	// Session が使う既定の保管庫をテスト用の一時ディレクトリに置く
+	dir, err := os.MkdirTemp("", "quickport-vault")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(statedir.DirEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestVault はトークンを1つ保存した保管庫を作り、そのパスを返す
+func newTestVault(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	v, err := Open(path, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rewrite は保管庫のファイルを読み、editで書き換えて保存する
+func rewrite(t *testing.T, path string, edit func(f *file)) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	edit(&f)
+	if data, err = json.Marshal(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 保存した値を同じパスフレーズで開き直して読めること、ファイルには平文を書かないこと
+func TestOpenRoundTrip(t *testing.T) {
+	path := newTestVault(t)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) {
+		t.Fatal("vault file contains the plaintext token")
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != statedir.SecretPerm {
+		t.Fatalf("vault perm = %o, want %o", info.Mode().Perm(), statedir.SecretPerm)
+	}
+
+	v, err := Open(path, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, ok := v.Get("token"); !ok || token != "secret-token" {
+		t.Fatalf("Get(token) = %q, %v", token, ok)
+	}
+
+	if err := v.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+	v, err = Open(path, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.Get("token"); ok {
+		t.Fatal("deleted entry is still in the vault")
+	}
+}
+
+// 違うパスフレーズでは開けないこと
+func TestOpenWrongPassphrase(t *testing.T) {
+	path := newTestVault(t)
+
+	if _, err := Open(path, "wrong passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("err = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+// 新しく作るときは短いパスフレーズを受け付けないこと
+func TestCreateWeakPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	if _, err := Open(path, "short"); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("err = %v, want ErrWeakPassphrase", err)
+	}
+}
+
+// 暗号文やnonceを書き換えたファイルは開けないこと
+func TestOpenTampered(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(f *file)
+	}{
+		{"data", func(f *file) { f.Data[0] ^= 0xff }},
+		{"nonce", func(f *file) { f.Nonce[0] ^= 0xff }},
+		{"short nonce", func(f *file) { f.Nonce = f.Nonce[:4] }},
+	}
+	for _, tt := range tests {
+		path := newTestVault(t)
+		rewrite(t, path, tt.edit)
+
+		v, err := Open(path, testPassphrase)
+		if err == nil {
+			t.Fatalf("%s: tampered vault was opened: %v", tt.name, v.entries)
+		}
+	}
+}
+
+// 作るときと異なる鍵の導出パラメータは、鍵を導出する前に拒否すること
+func TestOpenRejectsKDFParams(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(f *file)
+	}{
+		{"huge N", func(f *file) { f.KDF.N = 1 << 30 }},
+		{"r", func(f *file) { f.KDF.R = 1024 }},
+		{"p", func(f *file) { f.KDF.P = 64 }},
+		{"short salt", func(f *file) { f.KDF.Salt = f.KDF.Salt[:2] }},
+		{"name", func(f *file) { f.KDF.Name = "pbkdf2" }},
+	}
+	for _, tt := range tests {
+		path := newTestVault(t)
+		rewrite(t, path, tt.edit)
+
+		_, err := Open(path, testPassphrase)
+		if err == nil || errors.Is(err, ErrWrongPassphrase) {
+			t.Fatalf("%s: err = %v, want a KDF parameter error", tt.name, err)
+		}
+	}
+}
+
+// 開いていない場合はロック中を返し、環境変数のパスフレーズがあればそれで開くこと
+func TestSessionPassphraseEnv(t *testing.T) {
+	resetSession := func() {
+		sessionMutex.Lock()
+		session = nil
+		sessionMutex.Unlock()
+	}
+	resetSession()
+	t.Cleanup(func() {
+		resetSession()
+		os.Remove(DefaultPath())
+	})
+
+	t.Setenv(PassphraseEnv, "")
+	if _, err := Session(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("err = %v, want ErrLocked", err)
+	}
+	if Unlocked() {
+		t.Fatal("Unlocked() = true before the passphrase was given")
+	}
+
+	t.Setenv(PassphraseEnv, testPassphrase)
+	v, err := Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("token", "env-token"); err != nil {
+		t.Fatal(err)
+	}
+	if !Unlocked() {
+		t.Fatal("Unlocked() = false after Session")
+	}
+
+	// 別のプロセスでも同じパスフレーズで開ける
+	reopened, err := Open(DefaultPath(), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := reopened.Get("token"); token != "env-token" {
+		t.Fatalf("token = %q, want env-token", token)
+	}
+}
@@ -0,0 +1,102 @@
+package screens
+
+import (
+	"QuickPort/internal/acl"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	aLTitleStyle = lipgloss.NewStyle().
+			Border(lipgloss.DoubleBorder()).
+			Align(lipgloss.Center).
+			Padding(1).
+			Width(80).
+			Bold(true).
+			Foreground(lipgloss.Color("205"))
+	aLHelpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	aLErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+	aLSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("46")).Bold(true)
+)
+
+// アクセス制限の編集画面の Model
+type AccessListModel struct {
+	editor         textarea.Model
+	errorMessage   string
+	successMessage string
+}
+
+func InitialAccessListModel() AccessListModel {
+	editor := textarea.New()
+	editor.SetWidth(80)
+	editor.SetHeight(16)
+	editor.CharLimit = 0
+	editor.ShowLineNumbers = true
+	editor.Placeholder = "deny ip 198.51.100.0/24"
+
+	m := AccessListModel{editor: editor}
+
+	text, err := acl.ReadText(acl.DefaultPath())
+	if err != nil {
+		m.errorMessage = "読み込みに失敗しました: " + err.Error()
+	}
+	m.editor.SetValue(text)
+	m.editor.Focus()
+	return m
+}
+
+func (m AccessListModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m AccessListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "welcome"}
+			}
+		case "ctrl+s":
+			// 内容に誤りがあれば保存しない
+			if err := acl.Save(acl.DefaultPath(), m.editor.Value()); err != nil {
+				m.errorMessage = err.Error()
+				m.successMessage = ""
+				return m, nil
+			}
+			m.errorMessage = ""
+			m.successMessage = "保存しました（公開中の場合は数秒で反映されます）"
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(msg)
+	return m, cmd
+}
+
+func (m AccessListModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(aLTitleStyle.Render("🛡️ アクセス制限"))
+	b.WriteString("\n\n")
+	b.WriteString(aLHelpStyle.Render("接続を許可・拒否するIPアドレスとプレイヤー名を1行に1つずつ書きます（" + acl.DefaultPath() + "）"))
+	b.WriteString("\n\n")
+	b.WriteString(m.editor.View())
+	b.WriteString("\n\n")
+
+	if m.errorMessage != "" {
+		b.WriteString(aLErrorStyle.Render("❌ " + m.errorMessage))
+		b.WriteString("\n\n")
+	} else if m.successMessage != "" {
+		b.WriteString(aLSuccessStyle.Render("✅ " + m.successMessage))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString(aLHelpStyle.Render("Ctrl+S: 保存  •  Esc: 戻る"))
+	return b.String()
+}
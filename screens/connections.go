@@ -0,0 +1,298 @@
+package screens
+
+import (
+	"QuickPort/internal/core"
+	"QuickPort/internal/traffic"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	cDTitleStyle = lipgloss.NewStyle().
+			Border(lipgloss.DoubleBorder()).
+			Align(lipgloss.Center).
+			Padding(1).
+			Width(116).
+			Bold(true).
+			Foreground(lipgloss.Color("51"))
+	cDHeaderStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Bold(true)
+	cDRowStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	cDSelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("205")).Bold(true)
+	cDHelpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	cDInStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	cDOutStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+)
+
+// スパークラインに残すサンプル数（1秒ごと）
+const sparklineLength = 60
+
+// スパークラインの文字
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// 接続一覧の更新メッセージ
+type tickConnectionsMsg time.Time
+
+func doTickConnections() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickConnectionsMsg(t)
+	})
+}
+
+// 1本の接続の表示用の情報
+type connectionRow struct {
+	info    core.ConnectionInfo
+	rateIn  float64 // 直近1秒のバイト数
+	rateOut float64
+}
+
+// 接続一覧画面の Model
+type ConnectionsModel struct {
+	rows       []connectionRow
+	cursor     int
+	previous   map[string]core.ConnectionInfo // 前回の取得結果（転送速度の計算用）
+	lastUpdate time.Time
+	totalIn    uint64
+	totalOut   uint64
+	historyIn  []float64 // 全体の受信速度の履歴
+	historyOut []float64 // 全体の送信速度の履歴
+	message    string
+}
+
+func InitialConnectionsModel() ConnectionsModel {
+	m := ConnectionsModel{previous: make(map[string]core.ConnectionInfo)}
+	m.refresh(time.Now())
+	return m
+}
+
+func (m ConnectionsModel) Init() tea.Cmd {
+	return doTickConnections()
+}
+
+// refresh は公開中のクライアントから接続の一覧を取り直し、転送速度を計算する
+func (m *ConnectionsModel) refresh(now time.Time) {
+	client := getActiveTunnel()
+	if client == nil {
+		m.rows = nil
+		m.cursor = 0
+		return
+	}
+
+	elapsed := now.Sub(m.lastUpdate).Seconds()
+	connections := client.Connections()
+	current := make(map[string]core.ConnectionInfo, len(connections))
+	m.rows = m.rows[:0]
+	for _, conn := range connections {
+		row := connectionRow{info: conn}
+		if prev, ok := m.previous[conn.ID]; ok && elapsed > 0 {
+			row.rateIn = float64(conn.BytesIn-prev.BytesIn) / elapsed
+			row.rateOut = float64(conn.BytesOut-prev.BytesOut) / elapsed
+		}
+		m.rows = append(m.rows, row)
+		current[conn.ID] = conn
+	}
+	m.previous = current
+
+	// 全体の速度は切断された接続の分も含めて、クライアント全体の累計から求める
+	stats := client.Traffic()
+	if !m.lastUpdate.IsZero() && elapsed > 0 {
+		m.historyIn = appendSample(m.historyIn, float64(stats.BytesIn-m.totalIn)/elapsed)
+		m.historyOut = appendSample(m.historyOut, float64(stats.BytesOut-m.totalOut)/elapsed)
+	}
+	m.totalIn, m.totalOut = stats.BytesIn, stats.BytesOut
+	m.lastUpdate = now
+
+	if m.cursor >= len(m.rows) {
+		m.cursor = max(len(m.rows)-1, 0)
+	}
+}
+
+func appendSample(history []float64, value float64) []float64 {
+	history = append(history, value)
+	if len(history) > sparklineLength {
+		history = history[len(history)-sparklineLength:]
+	}
+	return history
+}
+
+func (m ConnectionsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickConnectionsMsg:
+		m.refresh(time.Time(msg))
+		return m, doTickConnections()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc", "q":
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "welcome"}
+			}
+		case "up":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down":
+			if m.cursor < len(m.rows)-1 {
+				m.cursor++
+			}
+		case "k", "delete":
+			m.kickSelected()
+		}
+	}
+	return m, nil
+}
+
+// kickSelected は選択中の接続を切断する
+func (m *ConnectionsModel) kickSelected() {
+	client := getActiveTunnel()
+	if client == nil || len(m.rows) == 0 {
+		return
+	}
+
+	row := m.rows[m.cursor]
+	if err := client.Kick(row.info.ID); err != nil {
+		m.message = fmt.Sprintf("切断できませんでした: %v", err)
+		return
+	}
+
+	name := row.info.PlayerName()
+	if name == "" {
+		name = row.info.RemoteAddr
+	}
+	m.message = fmt.Sprintf("%s を切断しました", name)
+}
+
+func (m ConnectionsModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(cDTitleStyle.Render("📊 接続一覧"))
+	b.WriteString("\n\n")
+
+	if getActiveTunnel() == nil {
+		b.WriteString(cDHelpStyle.Render("ポートを公開していません"))
+		b.WriteString("\n\n")
+		b.WriteString(cDHelpStyle.Render("Esc: 戻る"))
+		return b.String()
+	}
+
+	// 全体の転送速度
+	var currentIn, currentOut float64
+	if n := len(m.historyIn); n > 0 {
+		currentIn, currentOut = m.historyIn[n-1], m.historyOut[n-1]
+	}
+	b.WriteString(fmt.Sprintf("%s %s  %s/s  (累計 %s)\n",
+		cDInStyle.Render("↓ 受信"), cDInStyle.Render(sparkline(m.historyIn)),
+		formatRate(currentIn), traffic.FormatBytes(m.totalIn)))
+	b.WriteString(fmt.Sprintf("%s %s  %s/s  (累計 %s)\n\n",
+		cDOutStyle.Render("↑ 送信"), cDOutStyle.Render(sparkline(m.historyOut)),
+		formatRate(currentOut), traffic.FormatBytes(m.totalOut)))
+
+	header := formatColumns("ID", "接続元", "プレイヤー", "経過", "受信", "送信", "受信速度", "送信速度")
+	b.WriteString(cDHeaderStyle.Render(header))
+	b.WriteString("\n")
+
+	if len(m.rows) == 0 {
+		b.WriteString(cDHelpStyle.Render("接続はありません"))
+		b.WriteString("\n")
+	}
+
+	now := time.Now()
+	for i, row := range m.rows {
+		remote := row.info.RemoteAddr
+		if remote == "" {
+			remote = "-"
+		}
+		player := row.info.PlayerName()
+		if player == "" {
+			player = "-"
+		}
+		line := formatColumns(
+			row.info.ID,
+			remote,
+			player,
+			formatAge(now.Sub(row.info.Opened)),
+			traffic.FormatBytes(row.info.BytesIn),
+			traffic.FormatBytes(row.info.BytesOut),
+			formatRate(row.rateIn)+"/s",
+			formatRate(row.rateOut)+"/s",
+		)
+		if i == m.cursor {
+			b.WriteString(cDSelectedStyle.Render(line))
+		} else {
+			b.WriteString(cDRowStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.message != "" {
+		b.WriteString("\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render(m.message))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(cDHelpStyle.Render("↑↓: 選択  •  k/Delete: 選択した接続を切断  •  Esc: 戻る"))
+	return b.String()
+}
+
+// sparkline は値の履歴を棒グラフの文字列にする
+func sparkline(values []float64) string {
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	var b strings.Builder
+	for i := len(values); i < sparklineLength; i++ {
+		b.WriteRune(' ')
+	}
+	for _, v := range values {
+		level := 0
+		if peak > 0 {
+			level = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+func formatRate(bytesPerSecond float64) string {
+	return traffic.FormatBytes(uint64(bytesPerSecond))
+}
+
+func formatAge(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Hour {
+		return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// 接続一覧の各列の表示幅
+var connectionColumnWidths = []int{10, 24, 16, 9, 10, 10, 12, 12}
+
+// formatColumns は各列を表示幅に合わせて揃える（全角文字は2文字分として数える）
+func formatColumns(values ...string) string {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = fitWidth(value, connectionColumnWidths[i])
+	}
+	return strings.Join(cells, " ")
+}
+
+// fitWidth は文字列を表示幅widthに切り詰めるか、空白で埋める
+func fitWidth(s string, width int) string {
+	if lipgloss.Width(s) > width {
+		runes := []rune(s)
+		for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+			runes = runes[:len(runes)-1]
+		}
+		s = string(runes) + "…"
+	}
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
@@ -1,14 +1,10 @@
 package screens
 
 import (
-	"QuickPort/share"
-	"bytes"
-	"encoding/json"
-	"errors"
+	"QuickPort/internal/account"
+	"QuickPort/internal/api"
+	"context"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/cursor"
@@ -16,7 +12,6 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"gopkg.in/ini.v1"
 )
 
 var (
@@ -61,54 +56,11 @@ type CreateAccountModel struct {
 	loadding     bool
 }
 
-type createAccountRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
 type accountChan struct {
 	status  string
 	message string
 }
 
-// パスワードのバリデーション関数
-func validatePassword(password, confirmPassword string) error {
-	if len(password) < 5 {
-		return errors.New("パスワードは5文字以上である必要があります")
-	}
-
-	// 確認用パスワードと一致しているか
-	if password != confirmPassword {
-		return errors.New("パスワードが一致しません")
-	}
-
-	return nil
-}
-
-// 情報をiniファイルに書き出す関数
-func saveToFile(email string) error {
-	// 新しいiniファイルを作成
-	cfg := ini.Empty()
-
-	// セクションとキーを設定
-	section, err := cfg.NewSection("Account")
-	if err != nil {
-		return err
-	}
-	_, err = section.NewKey("Email", email)
-	if err != nil {
-		return err
-	}
-
-	// ファイルに保存（上書き）
-	err = cfg.SaveTo("accounts.ini")
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func InitialCreateAccountModel() CreateAccountModel {
 	s := spinner.New()
 	s.Spinner = spinner.Points
@@ -199,29 +151,21 @@ func (m CreateAccountModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				confirmPassword := m.inputs[2].Value()
 
 				// パスワードのバリデーション
-				if err := validatePassword(password, confirmPassword); err != nil {
+				if err := account.ValidatePassword(password, confirmPassword); err != nil {
 					m.errorMessage = err.Error() // エラーメッセージを設定
 					return m, nil
 				}
 
-				req := createAccountRequest{
+				req := api.SignupRequest{
 					Email:    email,
 					Password: confirmPassword,
 				}
 
-				// リクエストボディをJSONに変換
-				requestBody, err := json.Marshal(req)
-				if err != nil {
-					log.Printf("リクエストボディの作成に失敗しました: %v", err)
-					m.errorMessage = "リクエストの作成に失敗しました"
-					return m, nil
-				}
-
 				m.loadding = true
-				go sendCreateAccountRequest(requestBody, m.ch)
+				go sendCreateAccountRequest(req, m.ch)
 
 				// ファイルに保存
-				if err := saveToFile(email); err != nil {
+				if err := account.SaveAccountEmail(email); err != nil {
 					fmt.Println("ファイル保存エラー:", err)
 					return InitialCreateAccountModel(), nil
 				}
@@ -415,74 +359,17 @@ func (m CreateAccountModel) View() string {
 	return b.String()
 }
 
-func sendCreateAccountRequest(body []byte, ch chan accountChan) {
-	// HTTPSリクエストを送信
-	endpoint := share.BASE_API_URL + "/auth/signup"
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+func sendCreateAccountRequest(request api.SignupRequest, ch chan accountChan) {
+	message, err := account.CreateAccount(context.Background(), request.Email, request.Password)
 	if err != nil {
-		log.Printf("HTTPリクエストの作成に失敗しました: %v", err)
-		ch <- accountChan{
-			status:  "ERROR",
-			message: "HTTPリクエストの作成に失敗しました: " + err.Error(),
-		}
-		close(ch)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("HTTPリクエストの送信に失敗しました: %v", err)
-		ch <- accountChan{
-			status:  "ERROR",
-			message: "HTTPリクエストの送信に失敗しました: " + err.Error(),
-		}
-		close(ch)
-		return
-	}
-	defer resp.Body.Close()
-
-	// レスポンスボディを読み取る
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("レスポンスボディの読み取りに失敗しました: %v", err)
-		ch <- accountChan{
-			status:  "ERROR",
-			message: "レスポンスボディの読み取りに失敗しました: " + err.Error(),
-		}
-		close(ch)
-		return
-	}
-
-	// レスポンスボディをパース
-	var parsedResponse struct {
-		Message string `json:"message"`
-		Status  string `json:"status"`
-	}
-	err = json.Unmarshal(respBody, &parsedResponse)
-	if err != nil {
-		log.Printf("347 レスポンスボディのパースに失敗しました: %v", err)
-		ch <- accountChan{
-			status:  "ERROR",
-			message: "347 レスポンスボディのパースに失敗しました: " + err.Error(),
-		}
-		close(ch)
-		return
-	}
-
-	// パース結果をログに出力
-	log.Printf("レスポンス: message=%s, status=%s", parsedResponse.Message, parsedResponse.Status)
-
-	if parsedResponse.Status == "ERROR" {
 		ch <- accountChan{
 			status:  "ERROR",
-			message: parsedResponse.Message,
+			message: err.Error(),
 		}
 	} else {
 		ch <- accountChan{
 			status:  "OK",
-			message: parsedResponse.Message,
+			message: message,
 		}
 	}
 	close(ch) // チャンネルを閉じる
@@ -1,13 +1,10 @@
 package screens
 
 import (
-	"QuickPort/share"
-	"bytes"
-	"encoding/json"
-	"io"
-	"log"
-	"net/http"
-	"os"
+	"QuickPort/internal/account"
+	"QuickPort/internal/api"
+	"QuickPort/internal/core"
+	"context"
 	"strconv"
 	"strings"
 
@@ -16,7 +13,6 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"gopkg.in/ini.v1"
 )
 
 var (
@@ -57,23 +53,6 @@ var (
 		Render("トークン発行")
 )
 
-type RequestTokenMetadata struct {
-	LocalIP      string `json:"local_ip"`
-	LocalPort    int    `json:"local_port"`
-	ProtocolType string `json:"protocol_type"`
-}
-
-type RequestUserInfo struct {
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password"`
-	UserName string `json:"user_name,omitempty"`
-}
-
-type Request struct {
-	RequestTokenMetadata RequestTokenMetadata `json:"request_token_metadata"`
-	RequestUserInfo      RequestUserInfo      `json:"request_user_info"`
-}
-
 type tokenChan struct {
 	status  string
 	message string
@@ -96,7 +75,7 @@ func InitialGenerateTokenModel() GenerateTokenModel {
 	s.Spinner = spinner.Points
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 	m := GenerateTokenModel{
-		inputs:  make([]textinput.Model, 3),
+		inputs:  make([]textinput.Model, 4),
 		spinner: s,
 		ch:      make(chan tokenChan),
 	}
@@ -123,6 +102,9 @@ func InitialGenerateTokenModel() GenerateTokenModel {
 		case 2:
 			t.Placeholder = "25565"
 			t.CharLimit = 10
+		case 3:
+			t.Placeholder = "tcp"
+			t.CharLimit = 3
 		}
 
 		m.inputs[i] = t
@@ -174,30 +156,30 @@ func (m GenerateTokenModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				email := m.inputs[0].Value()
 				password := m.inputs[1].Value()
 				localPortStr := m.inputs[2].Value()
+				protocolType := strings.ToLower(strings.TrimSpace(m.inputs[3].Value()))
 
 				localPort, err := strconv.Atoi(localPortStr)
 				if err != nil {
 					m.errorMessage = "ポート番号は数値で入力してください"
 					return m, nil
 				}
-
-				var reqest Request
-				reqest.RequestUserInfo.Email = email
-				reqest.RequestUserInfo.Password = password
-				reqest.RequestTokenMetadata.LocalPort = localPort
-				reqest.RequestTokenMetadata.LocalIP = "127.0.0.1"
-				reqest.RequestTokenMetadata.ProtocolType = "tcp"
-
-				// リクエストボディをJSONに変換
-				requestBody, err := json.Marshal(reqest)
-				if err != nil {
-					log.Printf("リクエストボディの作成に失敗しました: %v", err)
-					m.errorMessage = "リクエストの作成に失敗しました"
+				if protocolType == "" {
+					protocolType = core.PROXY_TYPE_TCP
+				}
+				if protocolType != core.PROXY_TYPE_TCP && protocolType != core.PROXY_TYPE_UDP {
+					m.errorMessage = "プロトコルは tcp か udp を入力してください"
 					return m, nil
 				}
 
+				var reqest api.TokenRequest
+				reqest.UserInfo.Email = email
+				reqest.UserInfo.Password = password
+				reqest.Metadata.LocalPort = localPort
+				reqest.Metadata.LocalIP = "127.0.0.1"
+				reqest.Metadata.ProtocolType = protocolType
+
 				m.loadding = true
-				go sendTokenRequest(requestBody, m.ch)
+				go sendTokenRequest(reqest, m.ch)
 
 				return m, nil
 			}
@@ -255,118 +237,24 @@ func (m GenerateTokenModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func sendTokenRequest(body []byte, ch chan tokenChan) {
-	// HTTPSリクエストを送信
-	endpoint := share.BASE_API_URL + "/auth/token-issuance"
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("HTTPリクエストの作成に失敗しました: %v", err)
-		ch <- tokenChan{
-			status:  "ERROR",
-			message: "HTTPリクエストの作成に失敗しました: " + err.Error(),
-			token:   "",
-		}
-		close(ch)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func sendTokenRequest(request api.TokenRequest, ch chan tokenChan) {
+	result, err := account.IssueToken(context.Background(), request)
 	if err != nil {
-		log.Printf("HTTPリクエストの送信に失敗しました: %v", err)
-		ch <- tokenChan{
-			status:  "ERROR",
-			message: "HTTPリクエストの送信に失敗しました: " + err.Error(),
-			token:   "",
-		}
-		close(ch)
-		return
-	}
-	defer resp.Body.Close()
-
-	// レスポンスボディを読み取る
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("レスポンスボディの読み取りに失敗しました: %v", err)
-		ch <- tokenChan{
-			status:  "ERROR",
-			message: "レスポンスボディの読み取りに失敗しました: " + err.Error(),
-			token:   "",
-		}
-		close(ch)
-		return
-	}
-
-	// レスポンスボディをパース
-	var parsedResponse struct {
-		Message   string `json:"message"`
-		Status    string `json:"status"`
-		Token     string `json:"token"`
-		Email     string `json:"email,omitempty"`
-		Plan      string `json:"plan,omitempty"`
-		Bandwidth string `json:"bandwidth_limit,omitempty"`
-		ExpireAt  string `json:"expire_at,omitempty"`
-	}
-	err = json.Unmarshal(respBody, &parsedResponse)
-	if err != nil {
-		log.Printf("レスポンスボディのパースに失敗しました: %v", err)
-		ch <- tokenChan{
-			status:  "ERROR",
-			message: "レスポンスボディのパースに失敗しました: " + err.Error(),
-			token:   "",
-		}
-		close(ch)
-		return
-	}
-
-	// パース結果をログに出力
-	log.Printf("レスポンス: message=%s, status=%s, token=%s", parsedResponse.Message, parsedResponse.Status, parsedResponse.Token)
-
-	if parsedResponse.Status == "ERROR" {
 		ch <- tokenChan{
 			status:  "ERROR",
-			message: parsedResponse.Message,
+			message: err.Error(),
 			token:   "",
 		}
 	} else {
-		// トークンをファイルに書き出す
-		if err := writeTokenToFile(parsedResponse.Token); err != nil {
-			log.Printf("トークンのファイル書き出しに失敗しました: %v", err)
-			ch <- tokenChan{
-				status:  "ERROR",
-				message: "トークンのファイル書き出しに失敗しました",
-				token:   "",
-			}
-			return
-		}
-
-		// アカウント情報をaccounts.iniに保存
-		if err := updateAccountInfo(parsedResponse.Email, parsedResponse.Plan, parsedResponse.Bandwidth, parsedResponse.ExpireAt); err != nil {
-			log.Printf("アカウント情報の更新に失敗しました: %v", err)
-			// アカウント情報の更新に失敗してもトークンは有効なので、エラーにはしない
-		}
-
 		ch <- tokenChan{
 			status:  "OK",
-			message: parsedResponse.Message,
-			token:   parsedResponse.Token,
+			message: result.Message,
+			token:   result.Token,
 		}
 	}
 	close(ch) // チャンネルを閉じる
 }
 
-func writeTokenToFile(token string) error {
-	file, err := os.OpenFile("token", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(token)
-	return err
-}
-
 func (m *GenerateTokenModel) updateInputs(msg tea.Msg) tea.Cmd {
 	cmds := make([]tea.Cmd, len(m.inputs))
 
@@ -416,7 +304,7 @@ func (m GenerateTokenModel) View() string {
 			MarginTop(1).
 			MarginBottom(2)
 		
-		b.WriteString(tokenContainer.Render(gTTokenStyle.Render("Token: " + maskToken(m.token))))
+		b.WriteString(tokenContainer.Render(gTTokenStyle.Render("Token: " + account.MaskToken(m.token))))
 		b.WriteString("\n")
 		b.WriteString(instructionStyle.Render("➤ Enterキーで戻る"))
 		b.WriteString("\n\n")
@@ -433,11 +321,12 @@ func (m GenerateTokenModel) View() string {
 	var formContent strings.Builder
 	
 	// 入力フィールドのラベル
-	labels := []string{"メールアドレス", "パスワード", "Minecraftサーバのポート番号"}
+	labels := []string{"メールアドレス", "パスワード", "Minecraftサーバのポート番号", "プロトコル"}
 	descriptions := []string{
 		"アカウント作成時に使用したメールアドレス",
 		"アカウント作成時に設定したパスワード", 
 		"公開するMinecraftサーバのポート番号（例: 25565）",
+		"tcp: Java版（既定） / udp: 統合版(Bedrock, 19132)やボイスチャットMOD",
 	}
 	
 	for i := range m.inputs {
@@ -534,56 +423,3 @@ func (m GenerateTokenModel) View() string {
 
 	return b.String()
 }
-
-func maskToken(token string) string {
-	if len(token) < 10 {
-		// トークンが10文字未満の場合はそのまま返す
-		return token
-	}
-
-	// トークンの先頭8文字と末尾4文字を表示し、中間をマスク
-	if len(token) <= 16 {
-		prefix := token[:4]
-		suffix := token[len(token)-4:]
-		masked := strings.Repeat("*", len(token)-8)
-		return prefix + masked + suffix
-	}
-
-	// 長いトークンの場合
-	prefix := token[:8]              // 最初の8文字
-	suffix := token[len(token)-4:]   // 最後の4文字
-	masked := strings.Repeat("*", 16) // 中間16文字を'*'に置き換え
-
-	// マスクされたトークンを返す
-	return prefix + masked + suffix
-}
-
-// updateAccountInfo は accounts.ini にアカウント情報を更新する
-func updateAccountInfo(email, plan, bandwidth, expireAt string) error {
-	// accounts.ini ファイルを読み込み、存在しない場合は新しく作成
-	cfg, err := ini.Load("accounts.ini")
-	if err != nil {
-		// ファイルが存在しない場合は新しく作成
-		cfg = ini.Empty()
-	}
-
-	// Account セクションを取得または作成
-	section := cfg.Section("Account")
-	
-	// メールアドレス、プラン、帯域幅を設定（空でない場合のみ）
-	if email != "" {
-		section.Key("Email").SetValue(email)
-	}
-	if plan != "" {
-		section.Key("Plan").SetValue(plan)
-	}
-	if bandwidth != "" {
-		section.Key("Bandwidth").SetValue(bandwidth)
-	}
-	if expireAt != "" {
-		section.Key("ExpireAt").SetValue(expireAt)
-	}
-
-	// ファイルに保存
-	return cfg.SaveTo("accounts.ini")
-}
@@ -0,0 +1,24 @@
+package screens
+
+import (
+	"QuickPort/internal/account"
+	"errors"
+)
+
+// SwitchProfile は使用中のプロファイルを切り替え、次に起動したときも使うように accounts.ini に書き出す
+// このプロセスで公開中の場合は切り替えない
+func SwitchProfile(name string) error {
+	if err := account.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if getLocalClient() != nil {
+		return errors.New("公開を停止してから切り替えてください")
+	}
+	if err := account.SelectProfile(name); err != nil {
+		return err
+	}
+
+	// 切り替えたプロファイルを別のプロセスで公開中なら、その状態を表示する
+	go pollRemote()
+	return nil
+}
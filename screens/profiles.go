@@ -0,0 +1,218 @@
+package screens
+
+import (
+	"QuickPort/internal/account"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	pFTitleStyle = lipgloss.NewStyle().
+			Border(lipgloss.DoubleBorder()).
+			Align(lipgloss.Center).
+			Padding(1).
+			Width(60).
+			Bold(true).
+			Foreground(lipgloss.Color("205"))
+	pFFocusedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color("205")).
+			Padding(0, 1).
+			Bold(true).
+			Width(40)
+	pFItemStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Padding(0, 1).Width(40)
+	pFHelpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	pFErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+	pFConfirmStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Bold(true)
+)
+
+// プロファイルの切り替え画面の Model
+type ProfilesModel struct {
+	profiles      []string
+	current       string
+	focusIndex    int
+	adding        bool // 新しいプロファイルの名前を入力中
+	nameInput     textinput.Model
+	confirmDelete bool // 選択中のプロファイルを削除するか確認中
+	errorMessage  string
+}
+
+func InitialProfilesModel() ProfilesModel {
+	input := textinput.New()
+	input.Placeholder = "survival"
+	input.CharLimit = 32
+	input.Width = 32
+	input.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	m := ProfilesModel{nameInput: input}
+	m.reload()
+	return m
+}
+
+// reload はプロファイルの一覧を読み直し、使用中のプロファイルを選択する
+func (m *ProfilesModel) reload() {
+	m.profiles = account.ListProfiles()
+	m.current = account.CurrentProfile()
+	m.focusIndex = 0
+	for i, name := range m.profiles {
+		if name == m.current {
+			m.focusIndex = i
+		}
+	}
+	// accounts.ini にまだ無いプロファイルを --profile で指定した場合も一覧に出す
+	if m.profiles[m.focusIndex] != m.current {
+		m.profiles = append(m.profiles, m.current)
+		m.focusIndex = len(m.profiles) - 1
+	}
+}
+
+func (m ProfilesModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m ProfilesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if m.adding {
+			var cmd tea.Cmd
+			m.nameInput, cmd = m.nameInput.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+	if keyMsg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+
+	if m.adding {
+		return m.updateAdding(keyMsg)
+	}
+
+	if m.confirmDelete {
+		m.confirmDelete = false
+		if keyMsg.String() == "y" {
+			if err := account.DeleteProfile(m.profiles[m.focusIndex]); err != nil {
+				m.errorMessage = err.Error()
+				return m, nil
+			}
+			m.errorMessage = ""
+			m.reload()
+		}
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "esc", "q":
+		return m, func() tea.Msg {
+			return ScreenChangeMsg{Screen: "welcome"}
+		}
+	case "up":
+		if m.focusIndex > 0 {
+			m.focusIndex--
+		}
+	case "down":
+		if m.focusIndex < len(m.profiles)-1 {
+			m.focusIndex++
+		}
+	case "enter", " ":
+		return m.switchTo(m.profiles[m.focusIndex])
+	case "n":
+		m.adding = true
+		m.errorMessage = ""
+		m.nameInput.SetValue("")
+		return m, m.nameInput.Focus()
+	case "d":
+		name := m.profiles[m.focusIndex]
+		if name == account.DefaultProfile || name == m.current {
+			m.errorMessage = "既定のプロファイルと使用中のプロファイルは削除できません"
+			return m, nil
+		}
+		m.errorMessage = ""
+		m.confirmDelete = true
+	}
+	return m, nil
+}
+
+// updateAdding は新しいプロファイルの名前の入力を処理する
+func (m ProfilesModel) updateAdding(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.adding = false
+		m.nameInput.Blur()
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.nameInput.Value())
+		if err := account.ValidateProfileName(name); err != nil {
+			m.errorMessage = err.Error()
+			return m, nil
+		}
+		m.adding = false
+		m.nameInput.Blur()
+		return m.switchTo(name)
+	}
+
+	var cmd tea.Cmd
+	m.nameInput, cmd = m.nameInput.Update(msg)
+	return m, cmd
+}
+
+// switchTo はプロファイルを切り替えてメインメニューへ戻る
+func (m ProfilesModel) switchTo(name string) (tea.Model, tea.Cmd) {
+	if err := SwitchProfile(name); err != nil {
+		m.errorMessage = err.Error()
+		return m, nil
+	}
+	return m, func() tea.Msg {
+		return ScreenChangeMsg{Screen: "welcome"}
+	}
+}
+
+func (m ProfilesModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(pFTitleStyle.Render("プロファイル"))
+	b.WriteString("\n\n")
+	b.WriteString("サーバごとにアカウント・トークン・転送先を切り替えます。\n\n")
+
+	for i, name := range m.profiles {
+		label := name
+		if name == m.current {
+			label += "  ✓ 使用中"
+		}
+		if profile, err := account.LoadProfile(name); err == nil && profile.Email != "" {
+			label += "  " + profile.Email
+		}
+		if i == m.focusIndex {
+			b.WriteString("→ " + pFFocusedStyle.Render(label))
+		} else {
+			b.WriteString("  " + pFItemStyle.Render(label))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if m.adding {
+		b.WriteString("新しいプロファイルの名前（英数字・-・_）\n")
+		b.WriteString(m.nameInput.View())
+		b.WriteString("\n\n")
+	}
+	if m.confirmDelete {
+		b.WriteString(pFConfirmStyle.Render(fmt.Sprintf("%s のアカウント情報とトークンを削除しますか？ (y/N)", m.profiles[m.focusIndex])))
+		b.WriteString("\n\n")
+	}
+	if m.errorMessage != "" {
+		b.WriteString(pFErrorStyle.Render("❌ " + m.errorMessage))
+		b.WriteString("\n\n")
+	}
+
+	if m.adding {
+		b.WriteString(pFHelpStyle.Render("Enter: 作成して切り替え  Esc: キャンセル"))
+	} else {
+		b.WriteString(pFHelpStyle.Render("↑↓: 選択  Enter: 切り替え  n: 新規作成  d: 削除  Esc: 戻る"))
+	}
+	return b.String()
+}
@@ -0,0 +1,357 @@
+package screens
+
+import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/control"
+	"QuickPort/internal/core"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 実行中のFRPクライアント
+// 画面を切り替えても公開を続けられるように、画面のモデルとは別に保持する
+var (
+	activeClientMutex sync.Mutex
+	activeClient      *core.FRPClient
+	activeUnsubscribe func()            // activeClientの状態の購読をやめる
+	activeListener    net.Listener      // 別のプロセスから操作するための制御API
+	activeStarted     time.Time         // 公開を始めた時刻
+	remoteSnapshot    *control.Snapshot // 別のプロセス（up --daemon など）で公開中のトンネルの状態
+)
+
+// 画面へ届ける公開状態（最新の1件だけを残す）
+var (
+	statusFeedMutex sync.Mutex
+	statusFeed      = make(chan core.Status, 1)
+)
+
+// 別のプロセスの状態を取り直す間隔
+const remotePollInterval = time.Second
+
+// 別のプロセスの停止を待つ時間
+const remoteStopTimeout = 15 * time.Second
+
+// 公開状態が変わったことを知らせるメッセージ
+type StatusChangedMsg struct {
+	Status core.Status
+}
+
+// frpcの停止が完了したことを知らせるメッセージ
+type FrpcStoppedMsg struct {
+	Restart  bool  // 停止後に再度公開するか
+	Reloaded bool  // 別のプロセスで公開中のトンネルを停止せずに読み込み直した
+	Err      error // 別のプロセスを停止できなかった場合の理由
+}
+
+// tunnel は画面から扱う公開中のトンネル
+// このプロセスで動かしているFRPクライアントか、別のプロセスで公開中のトンネルのどちらか
+type tunnel interface {
+	Status() core.Status
+	RTT() time.Duration
+	Rejections() core.RejectionStats
+	Traffic() core.TrafficStats
+	Connections() []core.ConnectionInfo
+	Kick(connID string) error
+}
+
+// remoteTunnel は別のプロセスで公開中のトンネル。状態は watchRemote が定期的に取り直した値を返す
+type remoteTunnel struct {
+	snapshot control.Snapshot
+}
+
+func (r remoteTunnel) Status() core.Status                { return r.snapshot.Status }
+func (r remoteTunnel) RTT() time.Duration                 { return r.snapshot.RTT }
+func (r remoteTunnel) Rejections() core.RejectionStats    { return r.snapshot.Rejections }
+func (r remoteTunnel) Traffic() core.TrafficStats         { return r.snapshot.Traffic }
+func (r remoteTunnel) Connections() []core.ConnectionInfo { return r.snapshot.Connections }
+
+func (r remoteTunnel) Kick(connID string) error {
+	return control.Kick(account.SocketPath(), connID)
+}
+
+func setActiveClient(client *core.FRPClient) {
+	activeClientMutex.Lock()
+	defer activeClientMutex.Unlock()
+
+	if activeUnsubscribe != nil {
+		activeUnsubscribe()
+		activeUnsubscribe = nil
+	}
+	activeClient = client
+	if client == nil {
+		return
+	}
+
+	updates, cancel := client.Subscribe()
+	activeUnsubscribe = cancel
+	go forwardStatus(updates)
+
+	remoteSnapshot = nil
+	if activeListener == nil {
+		// 別のターミナルのCLIやTUIから、このプロセスの公開を操作できるようにする
+		listener, err := control.Listen(account.SocketPath())
+		if err != nil {
+			log.Printf("Failed to listen on %s: %v", account.SocketPath(), err)
+		} else {
+			activeListener = listener
+			activeStarted = time.Now()
+			go control.Serve(listener, localHandler{})
+		}
+	}
+}
+
+// startClient はクライアントを公開中として登録して起動する
+func startClient(client *core.FRPClient, errorCh chan error) {
+	setActiveClient(client)
+	runClient(client, errorCh)
+}
+
+// runClient はクライアントを起動し、エラーがあればerrorChへ送る
+// 停止や読み込み直しではなく、再接続を諦めて終了した場合は起動を任せたサーバも止める
+func runClient(client *core.FRPClient, errorCh chan error) {
+	go func() {
+		err := client.Start(context.Background())
+		if getLocalClient() == client {
+			account.StopLocalServer()
+		}
+		if err == nil {
+			return
+		}
+		if errorCh == nil {
+			log.Printf("FRP client exited: %v", err)
+			return
+		}
+		select {
+		case errorCh <- err:
+		default:
+		}
+	}()
+}
+
+// forwardStatus はクライアントの状態の変化を画面へ届ける
+func forwardStatus(updates <-chan core.Status) {
+	for status := range updates {
+		publishStatus(status)
+	}
+}
+
+func publishStatus(status core.Status) {
+	statusFeedMutex.Lock()
+	defer statusFeedMutex.Unlock()
+
+	// 画面がまだ受け取っていない古い状態は捨てる
+	select {
+	case statusFeed <- status:
+	default:
+		select {
+		case <-statusFeed:
+		default:
+		}
+		statusFeed <- status
+	}
+}
+
+// WaitForStatus は公開状態が変わるまで待ち、StatusChangedMsg を返すコマンド
+// 画面を切り替えても取りこぼさないように、AppModel が受け取るたびに発行し直す
+func WaitForStatus() tea.Cmd {
+	return func() tea.Msg {
+		return StatusChangedMsg{Status: <-statusFeed}
+	}
+}
+
+var watchRemoteOnce sync.Once
+
+// WatchRemote は別のプロセスで公開中のトンネルの監視を始める
+// 見つかった場合はそのトンネルの状態を表示し、停止・再起動もそちらへ頼む
+func WatchRemote() {
+	watchRemoteOnce.Do(func() {
+		go watchRemote()
+	})
+}
+
+func watchRemote() {
+	ticker := time.NewTicker(remotePollInterval)
+	defer ticker.Stop()
+
+	for {
+		pollRemote()
+		<-ticker.C
+	}
+}
+
+// pollRemote は制御APIから別のプロセスの状態を取り直し、変わっていれば画面へ届ける
+func pollRemote() {
+	if getLocalClient() != nil {
+		// このプロセスで公開中（制御APIも自分で待ち受けている）
+		return
+	}
+
+	var next *control.Snapshot
+	if snapshot, err := control.Stats(account.SocketPath()); err == nil {
+		next = &snapshot
+	}
+
+	activeClientMutex.Lock()
+	if activeClient != nil {
+		activeClientMutex.Unlock()
+		return
+	}
+	previous := remoteSnapshot
+	remoteSnapshot = next
+	activeClientMutex.Unlock()
+
+	var before, after core.Status
+	if previous != nil {
+		before = previous.Status
+	}
+	if next != nil {
+		after = next.Status
+	}
+	if !reflect.DeepEqual(before, after) {
+		publishStatus(after)
+	}
+}
+
+// currentStatus は公開中のトンネルの状態を返す（公開していなければゼロ値）
+func currentStatus() core.Status {
+	t := getActiveTunnel()
+	if t == nil {
+		return core.Status{}
+	}
+	return t.Status()
+}
+
+// getLocalClient は最後に起動したFRPクライアントを返す
+// 再接続を諦めた場合などの状態を表示できるよう、終了後もStopされるまでは保持する
+func getLocalClient() *core.FRPClient {
+	activeClientMutex.Lock()
+	defer activeClientMutex.Unlock()
+	return activeClient
+}
+
+// getActiveTunnel はこのプロセスのFRPクライアントを返す
+// 無ければ別のプロセスで公開中のトンネルを返し、どちらも無ければnilを返す
+func getActiveTunnel() tunnel {
+	activeClientMutex.Lock()
+	defer activeClientMutex.Unlock()
+
+	if activeClient != nil {
+		return activeClient
+	}
+	if remoteSnapshot != nil {
+		return remoteTunnel{snapshot: *remoteSnapshot}
+	}
+	return nil
+}
+
+// stopActiveClient は実行中のFRPクライアントを停止するコマンドを返す
+// 停止にはストリームの切断通知を伴うので、画面を止めないようにコマンドとして実行する
+func stopActiveClient(restart bool) tea.Cmd {
+	return func() tea.Msg {
+		activeClientMutex.Lock()
+		client, unsubscribe, listener := activeClient, activeUnsubscribe, activeListener
+		activeClient, activeUnsubscribe, activeListener = nil, nil, nil
+		remote := remoteSnapshot != nil
+		activeClientMutex.Unlock()
+
+		if client == nil && remote {
+			return stopRemote(restart)
+		}
+
+		if client != nil {
+			client.Stop()
+			if !restart {
+				// 公開を止めたら、起動したサーバも止める（再起動では止めない）
+				account.StopLocalServer()
+			}
+		}
+		if unsubscribe != nil {
+			// 停止までの状態の変化を届けてから購読をやめる
+			unsubscribe()
+		}
+		if listener != nil {
+			listener.Close()
+		}
+		return FrpcStoppedMsg{Restart: restart}
+	}
+}
+
+// stopRemote は別のプロセスで公開中のトンネルを停止する
+// 再起動の場合は停止せずに、設定とトークンを読み込み直させる
+func stopRemote(restart bool) tea.Msg {
+	if restart {
+		err := control.Reload(account.SocketPath())
+		return FrpcStoppedMsg{Reloaded: err == nil, Err: err}
+	}
+
+	if err := control.Stop(account.SocketPath()); err != nil {
+		return FrpcStoppedMsg{Err: err}
+	}
+	deadline := time.Now().Add(remoteStopTimeout)
+	for control.Running(account.SocketPath()) {
+		if time.Now().After(deadline) {
+			return FrpcStoppedMsg{Err: errors.New("停止を確認できませんでした")}
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	pollRemote()
+	return FrpcStoppedMsg{}
+}
+
+// reloadActiveClient はこのプロセスで公開中のクライアントを止め、トークンと設定を読み込み直して起動し直す
+func reloadActiveClient() error {
+	token, err := account.ReadToken()
+	if err != nil {
+		return err
+	}
+
+	client := getLocalClient()
+	if client == nil {
+		return control.ErrNotRunning
+	}
+
+	// 先に新しいクライアントを登録し、古いクライアントの終了で起動を任せたサーバを止めないようにする
+	newClient := account.NewFRPClient(token)
+	setActiveClient(newClient)
+	client.Stop()
+	runClient(newClient, nil)
+	return nil
+}
+
+// localHandler は別のプロセスからの制御APIのコマンドを、このプロセスのクライアントで実行する
+type localHandler struct{}
+
+func (localHandler) Snapshot(full bool) control.Snapshot {
+	activeClientMutex.Lock()
+	client, started := activeClient, activeStarted
+	activeClientMutex.Unlock()
+
+	if client == nil {
+		return control.Snapshot{PID: os.Getpid(), Started: started}
+	}
+	return control.NewSnapshot(client, os.Getpid(), started, full)
+}
+
+func (localHandler) Stop() {
+	stopActiveClient(false)()
+}
+
+func (localHandler) Reload() error {
+	return reloadActiveClient()
+}
+
+func (localHandler) Kick(connID string) error {
+	client := getLocalClient()
+	if client == nil {
+		return control.ErrNotRunning
+	}
+	return client.Kick(connID)
+}
@@ -1,9 +1,9 @@
 package screens
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -12,8 +12,9 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"QuickPort/internal/account"
+	"QuickPort/internal/config"
 	"QuickPort/internal/core"
-	"QuickPort/share"
 )
 
 
@@ -36,6 +37,7 @@ type StartFrpcModel struct {
 	successTimer    int
 	errorCh         chan error
 	hasError        bool
+	isTLSError      bool // サーバ証明書の検証に失敗したか
 }
 
 type getPortChan struct {
@@ -101,7 +103,7 @@ func InitialStartFrpcModel() StartFrpcModel {
 	}
 
 	// トークンファイルからトークンを読み取る
-	token, err := readTokenFromFile()
+	token, err := account.ReadToken()
 	if err != nil {
 		log.Printf("トークンの読み取りに失敗しました: %v", err)
 		m.errorMessage = "トークンの読み取りに失敗しました"
@@ -133,6 +135,8 @@ func (m StartFrpcModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// エラーが発生した場合
 		m.hasError = true
 		m.errorMessage = fmt.Sprintf("%v", msg.err)
+		var tlsErr *core.TLSVerificationError
+		m.isTLSError = errors.As(msg.err, &tlsErr)
 		
 		// エラー監視を再開
 		cmds = append(cmds, waitForError(m.errorCh))
@@ -143,15 +147,9 @@ func (m StartFrpcModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if !m.showSuccess && !m.hasError {
 			m.connectionTimer++
 			
-			// 進捗を自動的に進める
-			if !share.IsConnection {
-				if m.connectionTimer%15 == 0 && m.currentStep < m.maxSteps-1 {
-					m.currentStep++
-				}
-			} else if !m.showSuccess {
-				m.currentStep = m.maxSteps
-				m.showSuccess = true
-				m.successTimer = 0
+			// 接続が完了するまで進捗を自動的に進める（完了はStatusChangedMsgで受け取る）
+			if m.connectionTimer%15 == 0 && m.currentStep < m.maxSteps-1 {
+				m.currentStep++
 			}
 		} else if m.showSuccess {
 			m.successTimer++
@@ -164,6 +162,14 @@ func (m StartFrpcModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, doTick()
 	
+	case StatusChangedMsg:
+		if msg.Status.Connected() && !m.showSuccess && !m.hasError {
+			m.currentStep = m.maxSteps
+			m.showSuccess = true
+			m.successTimer = 0
+		}
+		return m, nil
+
 	case progressMsg:
 		if msg.step <= m.maxSteps {
 			m.currentStep = msg.step
@@ -207,16 +213,8 @@ func (m StartFrpcModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// FRPクライアントがまだ起動していない場合のみ起動
 	if !m.clientStarted && m.token != "" && !m.hasError {
 		// トークンからメタデータを取得し、FRPクライアントを初期化
-		m.clientService = core.NewFRPClient("163.44.96.225:5555", m.token)
-		go func() {
-			err := m.clientService.Start()
-			if err != nil {
-				select {
-				case m.errorCh <- err:
-				default:
-				}
-			}
-		}()
+		m.clientService = account.NewFRPClient(m.token)
+		startClient(m.clientService, m.errorCh)
 		m.clientStarted = true
 	}
 
@@ -265,8 +263,15 @@ func (m StartFrpcModel) View() string {
 			"",
 			fmt.Sprintf("📋 エラー詳細: %s", m.errorMessage),
 			"",
-			"� ESCキーでメイン画面に戻れます",
 		}
+		if m.isTLSError {
+			errorContent = append(errorContent,
+				"🔒 接続先が正しいリレーサーバではない可能性があります",
+				"   "+config.FileName+" の relay.server_name / relay.pinned_spki を確認してください",
+				"",
+			)
+		}
+		errorContent = append(errorContent, "� ESCキーでメイン画面に戻れます")
 		
 		b.WriteString(errorBoxStyle.Render(strings.Join(errorContent, "\n")))
 		
@@ -356,23 +361,3 @@ func (m StartFrpcModel) View() string {
 
 	return b.String()
 }
-
-// ReadTokenFromFile はトークンファイルから内容を読み取ります
-func readTokenFromFile() (string, error) {
-	filePath := "token"
-	// ファイルが存在するか確認
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Printf("ファイルが存在しません: %s", filePath)
-		return "", err
-	}
-
-	// ファイルを読み取る
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		log.Printf("ファイルの読み取りに失敗しました: %v", err)
-		return "", err
-	}
-
-	// ファイルの内容を文字列として返す
-	return string(data), nil
-}
@@ -0,0 +1,168 @@
+package screens
+
+import (
+	"QuickPort/internal/vault"
+	"errors"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	uVTitleStyle = lipgloss.NewStyle().
+			Border(lipgloss.DoubleBorder()).
+			Align(lipgloss.Center).
+			Padding(1).
+			Width(60).
+			Bold(true).
+			Foreground(lipgloss.Color("205"))
+	uVLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	uVFocusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	uVHelpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	uVErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+)
+
+// 保管庫のロックを解除する画面の Model
+// トークンを使う画面へ移る前に表示し、解除できたら next の画面へ移る
+type UnlockVaultModel struct {
+	next         string
+	creating     bool // 保管庫が無く、新しいパスフレーズを決める
+	focusIndex   int
+	inputs       []textinput.Model
+	errorMessage string
+}
+
+// NeedsUnlock は画面を開く前に保管庫のロックを解除する必要があるかどうかを返す
+// 環境変数にパスフレーズがあればそれで開く
+func NeedsUnlock() bool {
+	_, err := vault.Session()
+	return err != nil
+}
+
+func InitialUnlockVaultModel(next string) UnlockVaultModel {
+	m := UnlockVaultModel{next: next, creating: !vault.Exists(vault.DefaultPath())}
+
+	count := 1
+	if m.creating {
+		count = 2
+	}
+	m.inputs = make([]textinput.Model, count)
+	for i := range m.inputs {
+		t := textinput.New()
+		t.EchoMode = textinput.EchoPassword
+		t.EchoCharacter = '•'
+		t.CharLimit = 128
+		t.Width = 40
+		t.Cursor.Style = uVFocusedStyle
+		t.Placeholder = "パスフレーズ"
+		if i == 1 {
+			t.Placeholder = "パスフレーズを再入力"
+		}
+		m.inputs[i] = t
+	}
+	m.inputs[0].Focus()
+	m.inputs[0].PromptStyle = uVFocusedStyle
+	m.inputs[0].TextStyle = uVFocusedStyle
+	return m
+}
+
+func (m UnlockVaultModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m UnlockVaultModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "welcome"}
+			}
+		case "tab", "shift+tab", "up", "down":
+			m.focus((m.focusIndex + 1) % len(m.inputs))
+			return m, nil
+		case "enter":
+			// 新しく作る場合は確認用の入力欄へ進んでから決定する
+			if m.focusIndex < len(m.inputs)-1 {
+				m.focus(m.focusIndex + 1)
+				return m, nil
+			}
+			if err := m.unlock(); err != nil {
+				m.errorMessage = err.Error()
+				for i := range m.inputs {
+					m.inputs[i].SetValue("")
+				}
+				m.focus(0)
+				return m, nil
+			}
+			next := m.next
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: next}
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focusIndex], cmd = m.inputs[m.focusIndex].Update(msg)
+	return m, cmd
+}
+
+// unlock は入力されたパスフレーズで保管庫を開く（無ければ作る）
+func (m *UnlockVaultModel) unlock() error {
+	passphrase := m.inputs[0].Value()
+	if m.creating && passphrase != m.inputs[1].Value() {
+		return errors.New("パスフレーズが一致しません")
+	}
+	return vault.Unlock(passphrase)
+}
+
+func (m *UnlockVaultModel) focus(index int) {
+	m.focusIndex = index
+	for i := range m.inputs {
+		if i == index {
+			m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = uVFocusedStyle
+			m.inputs[i].TextStyle = uVFocusedStyle
+			continue
+		}
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = lipgloss.NewStyle()
+		m.inputs[i].TextStyle = lipgloss.NewStyle()
+	}
+}
+
+func (m UnlockVaultModel) View() string {
+	var b strings.Builder
+
+	if m.creating {
+		b.WriteString(uVTitleStyle.Render("パスフレーズの設定"))
+		b.WriteString("\n\n")
+		b.WriteString("トークンはパスフレーズで暗号化して保存します。8文字以上のパスフレーズを決めてください。\n")
+		b.WriteString(uVHelpStyle.Render("パスフレーズを忘れるとトークンを読み出せなくなります（発行し直せば使えます）"))
+	} else {
+		b.WriteString(uVTitleStyle.Render("保管庫のロック解除"))
+		b.WriteString("\n\n")
+		b.WriteString("保存したトークンを使うために、パスフレーズを入力してください。")
+	}
+	b.WriteString("\n\n")
+
+	labels := []string{"パスフレーズ", "パスフレーズ確認"}
+	for i := range m.inputs {
+		b.WriteString(uVLabelStyle.Render(labels[i]))
+		b.WriteString("\n")
+		b.WriteString(m.inputs[i].View())
+		b.WriteString("\n\n")
+	}
+
+	if m.errorMessage != "" {
+		b.WriteString(uVErrorStyle.Render("❌ " + m.errorMessage))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(uVHelpStyle.Render("Enter: 決定  Tab: 入力欄の切り替え  Esc: 戻る"))
+	b.WriteString("\n")
+	b.WriteString(uVHelpStyle.Render("ヘッドレスで使う場合は環境変数 " + vault.PassphraseEnv + " にパスフレーズを設定します"))
+	return b.String()
+}
@@ -1,12 +1,16 @@
 package screens
 
 import (
+	"QuickPort/internal/account"
+	"QuickPort/internal/config"
+	"QuickPort/internal/core"
+	"QuickPort/internal/traffic"
 	"QuickPort/share"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -14,7 +18,6 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"gopkg.in/ini.v1"
 )
 
 // 画面切り替えメッセージ
@@ -50,6 +53,7 @@ type ServerStatusChan struct {
 
 // アカウントステータス構造体
 type AccountStatus struct {
+	profile   string
 	username  string
 	plan      string
 	bandwidth string
@@ -70,8 +74,13 @@ type WelcomeScreen struct {
 	showBanner            bool
 	bannerOffset          int
 	releaseMessage        string // GitHubリリースメッセージ
+	stoppingFrpc          bool   // frpcの停止処理中
+	frpcMessage           string // 停止・再起動の結果メッセージ
 }
 
+// メニューの項目数
+const welcomeMenuCount = 8
+
 func NewWelcomeScreen() WelcomeScreen {
 	accountStatus := getAccountStatus()
 	releaseMessage := getReleaseMessage()
@@ -136,7 +145,7 @@ func (m WelcomeScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.focusIndex--
 			}
 		case "down":
-			if m.focusIndex < 2 {
+			if m.focusIndex < welcomeMenuCount-1 {
 				m.focusIndex++
 			}
 		case "1":
@@ -151,13 +160,34 @@ func (m WelcomeScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "3":
 			m.focusIndex = 2
-			if share.IsRunningFrpc {
+			if currentStatus().Running {
 				// frpcが起動している場合は、再度起動しないようにする
 				return m, nil
 			}
 			return m, func() tea.Msg {
 				return ScreenChangeMsg{Screen: "start_frpc"}
 			}
+		case "4":
+			m.focusIndex = 3
+			return m.stopFrpc(false)
+		case "5":
+			m.focusIndex = 4
+			return m.stopFrpc(true)
+		case "6":
+			m.focusIndex = 5
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "access_list"}
+			}
+		case "7":
+			m.focusIndex = 6
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "connections"}
+			}
+		case "8", "p":
+			m.focusIndex = 7
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "profiles"}
+			}
 		case "enter", " ":
 			switch m.focusIndex {
 			case 0:
@@ -169,13 +199,29 @@ func (m WelcomeScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return ScreenChangeMsg{Screen: "generate_token"}
 				}
 			case 2:
-				if share.IsRunningFrpc {
+				if currentStatus().Running {
 					// frpcが起動している場合は、再度起動しないようにする
 					return m, nil
 				}
 				return m, func() tea.Msg {
 					return ScreenChangeMsg{Screen: "start_frpc"}
 				}
+			case 3:
+				return m.stopFrpc(false)
+			case 4:
+				return m.stopFrpc(true)
+			case 5:
+				return m, func() tea.Msg {
+					return ScreenChangeMsg{Screen: "access_list"}
+				}
+			case 6:
+				return m, func() tea.Msg {
+					return ScreenChangeMsg{Screen: "connections"}
+				}
+			case 7:
+				return m, func() tea.Msg {
+					return ScreenChangeMsg{Screen: "profiles"}
+				}
 			}
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
@@ -189,6 +235,29 @@ func (m WelcomeScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return "runtime_update"
 			})
 		}
+	case FrpcStoppedMsg:
+		m.stoppingFrpc = false
+		if msg.Err != nil {
+			m.frpcMessage = "別のプロセスで公開中のポートを操作できませんでした: " + msg.Err.Error()
+			return m, nil
+		}
+		if msg.Reloaded {
+			m.frpcMessage = "設定とトークンを読み込み直して接続し直しています"
+			return m, nil
+		}
+		if msg.Restart {
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "start_frpc"}
+			}
+		}
+		m.frpcMessage = "ポートの公開を停止しました"
+		return m, nil
+	case StatusChangedMsg:
+		// 状態が変わったときだけ描き直す
+		if msg.Status.Kicked {
+			m.frpcMessage = "サーバーからキックされたため公開を終了しました"
+		}
+		return m, nil
 	case UpdateAccountStatusMsg:
 		// アカウント情報を更新
 		m.accountStatus = getAccountStatus()
@@ -198,6 +267,27 @@ func (m WelcomeScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// 公開中のfrpcを停止する。restartがtrueの場合は停止後にもう一度公開する
+func (m WelcomeScreen) stopFrpc(restart bool) (tea.Model, tea.Cmd) {
+	if m.stoppingFrpc {
+		return m, nil
+	}
+	if !currentStatus().Running {
+		if restart {
+			// 停止中なら再起動は通常の公開と同じ
+			return m, func() tea.Msg {
+				return ScreenChangeMsg{Screen: "start_frpc"}
+			}
+		}
+		m.frpcMessage = "ポートは公開されていません"
+		return m, nil
+	}
+
+	m.stoppingFrpc = true
+	m.frpcMessage = ""
+	return m, stopActiveClient(restart)
+}
+
 // ランタイムアップデートのための関数
 func updateRuntimeStatus(m *WelcomeScreen) tea.Cmd {
 	m.serverActive = checkServerStatus()
@@ -252,6 +342,11 @@ func (m WelcomeScreen) View() string {
 		"🆕 アカウント作成",
 		"🔑 トークン生成", 
 		"🚀 ポート公開",
+		"🛑 公開停止",
+		"🔄 公開を再起動",
+		"🛡️ アクセス制限",
+		"📊 接続一覧",
+		"👥 プロファイル切り替え",
 	}
 
 	var leftView strings.Builder
@@ -299,6 +394,14 @@ func (m WelcomeScreen) View() string {
 		Italic(true)
 	leftView.WriteString(quitStyle.Render("  [q] 終了"))
 
+	if m.stoppingFrpc {
+		leftView.WriteString("\n\n")
+		leftView.WriteString("  " + m.spinner.View() + " 公開を停止しています...")
+	} else if m.frpcMessage != "" {
+		leftView.WriteString("\n\n")
+		leftView.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Render("  " + m.frpcMessage))
+	}
+
 	// 右側のステータス - より詳細に
 	var statusIcon, statusText string
 	var statusStyle lipgloss.Style
@@ -348,7 +451,7 @@ func (m WelcomeScreen) View() string {
 		Width(116).
 		Align(lipgloss.Center)
 	
-	accountHeader := accountHeaderStyle.Render("👤 アカウント情報")
+	accountHeader := accountHeaderStyle.Render("👤 アカウント情報（プロファイル: " + m.accountStatus.profile + "）")
 	
 	accountContentStyle := lipgloss.NewStyle().
 		Width(116).
@@ -377,21 +480,76 @@ func (m WelcomeScreen) View() string {
 	
 	connectionHeader := connectionHeaderStyle.Render("🔗 接続情報")
 	
+	var connState core.ConnState
+	var rtt time.Duration
+	var players []string
+	var rejections core.RejectionStats
+	var trafficStats core.TrafficStats
+	if t := getActiveTunnel(); t != nil {
+		connState = t.Status().State
+		rtt = t.RTT()
+		rejections = t.Rejections()
+		trafficStats = t.Traffic()
+		for _, conn := range t.Connections() {
+			if conn.PlayerName() != "" {
+				players = append(players, fmt.Sprintf("%s (%s)", conn.PlayerName(), conn.Minecraft.Version()))
+			}
+		}
+	}
+
+	status := currentStatus()
 	var connectionContent string
-	if share.IsConnection {
+	if status.Connected() {
 		connectionBoxStyle := lipgloss.NewStyle().
 			Width(116).
 			Padding(1, 2).
 			Border(lipgloss.RoundedBorder()).
 			BorderForeground(lipgloss.Color("82"))
+		if rejections.UnderAttack {
+			connectionBoxStyle = connectionBoxStyle.BorderForeground(lipgloss.Color("196"))
+		}
 		
+		routeFormat := "公開IP: %s\n解放中ポート: %s"
+		if strings.Contains(status.Route, "\n") {
+			// 複数のプロキシを公開している場合は1行ずつ表示する
+			routeFormat = "公開IP: %s\n解放中ポート:\n%s"
+		}
 		connectionContent = fmt.Sprintf(
-			"🟢 接続中\n"+
-			"公開IP: %s\n解放中ポート: %s",
-			lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true).Render(share.PublicAddr),
-			lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true).Render(share.Route),
+			"🟢 接続中\n"+routeFormat,
+			lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true).Render(status.PublicAddr),
+			lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true).Render(status.Route),
 		)
+		if rtt > 0 {
+			connectionContent += fmt.Sprintf("\nRTT: %s",
+				lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true).Render(rtt.Round(time.Millisecond).String()))
+		}
+		connectionContent += fmt.Sprintf("\n通信量: 今日 ↓%s ↑%s  •  今月 ↓%s ↑%s",
+			traffic.FormatBytes(trafficStats.Today.In), traffic.FormatBytes(trafficStats.Today.Out),
+			traffic.FormatBytes(trafficStats.Month.In), traffic.FormatBytes(trafficStats.Month.Out))
+		if trafficStats.Shaping {
+			connectionContent += fmt.Sprintf("（%s/s に制限中）", traffic.FormatBytes(uint64(trafficStats.BandwidthLimit)))
+		}
+		if len(players) > 0 {
+			connectionContent += fmt.Sprintf("\nプレイヤー(%d): %s", len(players),
+				lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Render(strings.Join(players, ", ")))
+		}
+		if rejections.Total() > 0 {
+			connectionContent += fmt.Sprintf("\n拒否した接続: %d件（接続数の制限 %d / 同一IPの制限 %d / 同時接続数 %d / アクセス制限 %d）",
+				rejections.Total(), rejections.RateLimited, rejections.IPRateLimited, rejections.TooManyStreams, rejections.AccessDenied)
+		}
+		if rejections.UnderAttack {
+			connectionContent += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true).
+				Render("⚠️ 大量の接続を拒否しています。攻撃を受けている可能性があります")
+		}
 		connectionContent = connectionBoxStyle.Render(connectionContent)
+	} else if reconnectStatus := renderReconnectStatus(connState); reconnectStatus != "" {
+		connectionBoxStyle := lipgloss.NewStyle().
+			Width(116).
+			Padding(1, 2).
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("220"))
+
+		connectionContent = connectionBoxStyle.Render(reconnectStatus)
 	} else {
 		connectionBoxStyle := lipgloss.NewStyle().
 			Width(116).
@@ -422,7 +580,7 @@ func (m WelcomeScreen) View() string {
 		Width(116).
 		Italic(true)
 	
-	help := helpStyle.Render("↑↓: 選択  •  Enter/Space: 実行  •  1-3: 直接選択  •  q: 終了")
+	help := helpStyle.Render("↑↓: 選択  •  Enter/Space: 実行  •  1-8: 直接選択  •  q: 終了")
 
 	// すべてを結合
 	return lipgloss.JoinVertical(
@@ -441,42 +599,50 @@ func (m WelcomeScreen) View() string {
 	)
 }
 
-// 認証サーバがオンラインか確認する関数
-func checkServerStatus() bool {
-	// pingエンドポイントにリクエストを送信
-	parsedURL, err := url.Parse(share.BASE_API_URL + "/ping")
-	if err != nil {
-		return false
-	}
-	req := &http.Request{
-		Method: "GET",
-		URL:    parsedURL,
+// 再接続中・再接続待ちの状態を表示用の文字列にする（該当しない場合は空文字）
+func renderReconnectStatus(state core.ConnState) string {
+	reason := ""
+	if state.LastError != "" {
+		reason = "\n切断理由: " + state.LastError
 	}
+	highlight := lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Bold(true)
 
-	client := &http.Client{
-		Timeout: 5 * time.Second, // タイムアウトを5秒に設定
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return false
-	} else {
-		if resp.StatusCode == http.StatusOK {
-			return true
-		} else {
-			return false
+	switch state.Kind {
+	case core.StateBackoff:
+		remaining := time.Until(state.NextAttempt).Round(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		return fmt.Sprintf("🟡 再接続待機中 (%d回目)\n次の再接続まで: %s%s",
+			state.Attempt, highlight.Render(remaining.String()), reason)
+	case core.StateConnecting:
+		if state.Attempt == 0 {
+			return ""
 		}
+		return fmt.Sprintf("🟡 再接続中... (%d回目)%s", state.Attempt, reason)
+	case core.StateGaveUp:
+		return fmt.Sprintf("🔴 %d回再接続に失敗したため公開を終了しました%s\n[5] 公開を再起動 で再度接続できます",
+			state.Attempt, reason)
 	}
+	return ""
+}
+
+// 認証サーバがオンラインか確認する関数
+func checkServerStatus() bool {
+	// pingエンドポイントにリクエストを送信（再試行を含めて5秒まで）
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return account.Ping(ctx) == nil
 }
 
 // GitHubリリースメッセージを取得する関数
 func getReleaseMessage() string {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: config.Get().Timeouts.API,
 	}
 
 	// WebサイトからHTMLを取得
-	resp, err := client.Get("https://qp.natyosu.com/") // または適切なWebサイトのURL
+	resp, err := client.Get(config.Get().API.WebsiteURL)
 	if err != nil {
 		log.Printf("Webサイトからのメッセージ取得に失敗しました: %v", err)
 		return ""
@@ -526,11 +692,14 @@ func getReleaseMessage() string {
 
 // ユーザ情報を取得する関数
 func getAccountStatus() AccountStatus {
-	// iniファイルを読み込む
-	cfg, err := ini.Load("accounts.ini")
+	profile := account.CurrentProfile()
+
+	// iniファイルから使用中のプロファイルの情報を読み込む
+	saved, err := account.LoadProfile(profile)
 	if err != nil {
 		log.Printf("accounts.iniの読み込みに失敗しました: %v", err)
 		return AccountStatus{
+			profile:   profile,
 			username:  "アカウント情報が見つかりません",
 			plan:      "トークン未発行",
 			bandwidth: "不明",
@@ -538,12 +707,10 @@ func getAccountStatus() AccountStatus {
 		}
 	}
 
-	// セクション "Account" から情報を取得
-	section := cfg.Section("Account")
-	email := section.Key("Email").String()
-	plan := section.Key("Plan").String()
-	bandwidth := section.Key("Bandwidth").String()
-	expireAt := section.Key("ExpireAt").String()
+	email := saved.Email
+	plan := saved.Plan
+	bandwidth := saved.Bandwidth
+	expireAt := saved.ExpireAt
 
 	// ユーザ名の表示形式を決定（Emailから生成）
 	var displayUsername string
@@ -578,6 +745,7 @@ func getAccountStatus() AccountStatus {
 	}
 
 	return AccountStatus{
+		profile:   profile,
 		username:  displayUsername,
 		plan:      plan,
 		bandwidth: bandwidth,
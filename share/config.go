@@ -1,7 +1,6 @@
 package share
 
+// 接続先のURLなどは internal/config で設定する
 const (
-	VERSION          = "2.0.0"
-	RELEASE_ENDPOINT = "https://api.github.com/repos/natyosu3/QuickPort/releases/latest"
-	BASE_API_URL     = "https://qp-auth-api-v2.natyosu.com"
+	VERSION = "2.0.0"
 )
@@ -1,8 +0,0 @@
-package share
-
-var (
-    IsRunningFrpc bool
-    PublicAddr    string
-    Route         string
-	IsConnection  bool // 新規: 接続状態を示すフラグ
-)